@@ -0,0 +1,138 @@
+// Package dhattack recovers Diffie-Hellman private keys from parties which
+// take any public value they are handed, building on the set5 DH types.
+package dhattack
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"mtsn"
+	set5 "set5/challenges"
+)
+
+// SessionMAC is the MAC a Victim sends along with its message: HMAC-SHA256,
+// keyed with the session key.
+func SessionMAC(session *big.Int, msg []byte) []byte {
+	return mtsn.HMACSum(sha256.New, session.Bytes(), msg)
+}
+
+// MACOracle hands public to a party with a static private key, and returns
+// the message it answered with along with its MAC under the session key.
+type MACOracle func(public *big.Int) ([]byte, []byte, error)
+
+// Victim is a party that keeps the same private key across exchanges, and
+// answers every public value with a MAC'd message.
+type Victim struct {
+	Message []byte
+
+	// validate makes the victim check public values against its group
+	validate bool
+	dh       *set5.DiffieHellman
+}
+
+// NewVictim sets up a victim with a fresh private key in group.
+func NewVictim(group *set5.DHGroup, validate bool) (*Victim, error) {
+	dh, err := set5.NewDiffieHellmanGroup(group)
+	if err != nil {
+		return nil, err
+	}
+	return &Victim{[]byte("crazy flamboyant for the rap enjoyment"), validate, dh}, nil
+}
+
+// Public is the public value of the victim.
+func (v *Victim) Public() *big.Int {
+	return v.dh.MyPublic
+}
+
+// Respond works out the session key for public, and MACs v.Message with it.
+// It can be used as a MACOracle.
+func (v *Victim) Respond(public *big.Int) ([]byte, []byte, error) {
+	var session *big.Int
+	if v.validate {
+		var err error
+		session, err = v.dh.ValidSessionKey(public)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		session = v.dh.SessionKey(public)
+	}
+	return v.Message, SessionMAC(session, v.Message), nil
+}
+
+// NewSubgroupTestGroup builds a group p = j*q + 1 with q a prime of qBits
+// bits, where j is a product of distinct primes below maxFactor whose product
+// is bigger than q. That leaves the subgroup of order q open to a small
+// subgroup attack, quickly enough for tests. G generates the subgroup of
+// order q.
+func NewSubgroupTestGroup(qBits int, maxFactor int64) (*set5.DHGroup, error) {
+	primes := smallPrimes(maxFactor)
+	if len(primes) < 2 {
+		return nil, fmt.Errorf("No odd primes below %d to build j with", maxFactor)
+	}
+
+	q, err := rand.Prime(rand.Reader, qBits)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		j, err := randomSmoothNumber(q, primes)
+		if err != nil {
+			return nil, err
+		}
+		p := new(big.Int).Mul(j, q)
+		p.Add(p, mtsn.Big.One)
+		if !p.ProbablyPrime(20) {
+			continue
+		}
+
+		for h := int64(2); ; h++ {
+			g := new(big.Int).Exp(big.NewInt(h), j, p)
+			if g.Cmp(mtsn.Big.One) != 0 {
+				name := fmt.Sprintf("test%d", p.BitLen())
+				return &set5.DHGroup{Name: name, P: p, G: g, Q: q}, nil
+			}
+		}
+	}
+}
+
+// randomSmoothNumber picks 2 times distinct primes out of primes (which all
+// have to be odd) until the product is bigger than q.
+func randomSmoothNumber(q *big.Int, primes []int64) (*big.Int, error) {
+	j := big.NewInt(2)
+	used := make(map[int64]bool)
+	for j.Cmp(q) <= 0 {
+		if len(used) == len(primes) {
+			return nil, fmt.Errorf("Primes below %d multiply to less than q", primes[len(primes)-1]+1)
+		}
+		i, err := rand.Int(rand.Reader, big.NewInt(int64(len(primes))))
+		if err != nil {
+			return nil, err
+		}
+		r := primes[i.Int64()]
+		if used[r] || q.Cmp(big.NewInt(r)) == 0 {
+			continue
+		}
+		used[r] = true
+		j.Mul(j, big.NewInt(r))
+	}
+	return j, nil
+}
+
+// smallPrimes lists the odd primes below bound, with a sieve.
+func smallPrimes(bound int64) []int64 {
+	var primes []int64
+	composite := make([]bool, bound)
+	for i := int64(3); i < bound; i += 2 {
+		if composite[i] {
+			continue
+		}
+		primes = append(primes, i)
+		for k := i * i; k < bound; k += 2 * i {
+			composite[k] = true
+		}
+	}
+	return primes
+}
@@ -0,0 +1,105 @@
+package dhattack
+
+import (
+	"math/big"
+	"mtsn"
+	set5 "set5/challenges"
+	"testing"
+)
+
+func TestNewSubgroupTestGroup(t *testing.T) {
+	group, err := NewSubgroupTestGroup(64, 1<<16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !group.P.ProbablyPrime(20) || !group.Q.ProbablyPrime(20) {
+		t.Fatalf("p = %v and q = %v should both be prime", group.P, group.Q)
+	}
+	if new(big.Int).Exp(group.G, group.Q, group.P).Cmp(mtsn.Big.One) != 0 {
+		t.Errorf("g = %v does not have order q", group.G)
+	}
+
+	j := new(big.Int).Sub(group.P, mtsn.Big.One)
+	j.Div(j, group.Q)
+	product := big.NewInt(1)
+	for _, r := range mtsn.SmallFactors(j, 1<<16) {
+		product.Mul(product, r)
+	}
+	if product.Cmp(group.Q) <= 0 {
+		t.Errorf("Small factors of j multiply to %v, which is not above q = %v", product, group.Q)
+	}
+}
+
+func TestSubgroupAttack(t *testing.T) {
+	group, err := NewSubgroupTestGroup(64, 1<<16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	victim, err := NewVictim(group, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, m, err := SubgroupAttack(group, victim.Respond, 1<<16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Cmp(group.Q) <= 0 {
+		t.Fatalf("Only learnt the key mod %v", m)
+	}
+	if x.Cmp(victim.dh.MyPrivate) != 0 {
+		t.Errorf("Recovered key %v, want %v", x, victim.dh.MyPrivate)
+	}
+	if new(big.Int).Exp(group.G, x, group.P).Cmp(victim.Public()) != 0 {
+		t.Errorf("g^x does not give the public value of the victim")
+	}
+}
+
+func TestSubgroupAttackPartial(t *testing.T) {
+	group, err := NewSubgroupTestGroup(64, 1<<16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	victim, err := NewVictim(group, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the factors below 1000 get used, so only part of the key comes out
+	x, m, err := SubgroupAttack(group, victim.Respond, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if new(big.Int).Mod(victim.dh.MyPrivate, m).Cmp(x) != 0 {
+		t.Errorf("Recovered %v mod %v, but the key is %v", x, m, victim.dh.MyPrivate)
+	}
+}
+
+func TestSubgroupAttackValidated(t *testing.T) {
+	group, err := NewSubgroupTestGroup(64, 1<<16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	victim, err := NewVictim(group, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := SubgroupAttack(group, victim.Respond, 1<<16); err == nil {
+		t.Errorf("A victim validating public values should stop the attack")
+	}
+
+	// Validation still lets honest parties through
+	other, err := set5.NewDiffieHellmanGroup(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, mac, err := victim.Respond(other.MyPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := other.SessionKey(victim.Public())
+	if string(SessionMAC(session, msg)) != string(mac) {
+		t.Errorf("MAC does not match the honest session key")
+	}
+}
@@ -0,0 +1,82 @@
+package dhattack
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"mtsn"
+	set5 "set5/challenges"
+)
+
+// SubgroupElement finds an element of order r modulo the prime p, where r is a
+// prime dividing p - 1.
+func SubgroupElement(p *big.Int, r *big.Int) (*big.Int, error) {
+	exponent := new(big.Int).Sub(p, mtsn.Big.One)
+	if new(big.Int).Mod(exponent, r).Sign() != 0 {
+		return nil, fmt.Errorf("%v does not divide p - 1", r)
+	}
+	exponent.Div(exponent, r)
+
+	for {
+		h, err := rand.Int(rand.Reader, p)
+		if err != nil {
+			return nil, err
+		}
+		h.Exp(h, exponent, p)
+		if h.Cmp(mtsn.Big.One) > 0 {
+			return h, nil
+		}
+	}
+}
+
+// SubgroupAttack is the Pohlig-Hellman style small subgroup confinement
+// attack. For every prime r below bound dividing j = (p - 1) / q, it sends
+// oracle an element h of order r. The session key h^x can only take r
+// values, so trying them all against the MAC gives x mod r. The residues are
+// combined with CRT, and the result is returned as x mod m along with m.
+//
+// When m is bigger than group.Q, the private key is x itself. Otherwise the
+// rest of it has to come from somewhere else.
+func SubgroupAttack(group *set5.DHGroup, oracle MACOracle, bound int64) (*big.Int, *big.Int, error) {
+	j := new(big.Int).Sub(group.P, mtsn.Big.One)
+	j.Div(j, group.Q)
+
+	var residues, moduli []*big.Int
+	for _, r := range mtsn.SmallFactors(j, bound) {
+		h, err := SubgroupElement(group.P, r)
+		if err != nil {
+			return nil, nil, err
+		}
+		msg, mac, err := oracle(h)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Oracle refused element of order %v: %v", r, err)
+		}
+
+		residue, err := subgroupLog(group.P, h, r, msg, mac)
+		if err != nil {
+			return nil, nil, err
+		}
+		residues = append(residues, residue)
+		moduli = append(moduli, r)
+	}
+
+	if len(moduli) == 0 {
+		return nil, nil, fmt.Errorf("No factors of j below %d to attack", bound)
+	}
+	return mtsn.CRT(residues, moduli)
+}
+
+// subgroupLog finds k below r such that msg MAC'd with the session key h^k
+// gives mac.
+func subgroupLog(p *big.Int, h *big.Int, r *big.Int, msg []byte, mac []byte) (*big.Int, error) {
+	session := big.NewInt(1)
+	for k := int64(0); k < r.Int64(); k++ {
+		if bytes.Equal(SessionMAC(session, msg), mac) {
+			return big.NewInt(k), nil
+		}
+		session.Mul(session, h)
+		session.Mod(session, p)
+	}
+	return nil, fmt.Errorf("No session key in the subgroup of order %v matches the MAC", r)
+}
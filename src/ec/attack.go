@@ -0,0 +1,366 @@
+package ec
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"mtsn"
+)
+
+// The message a victim MACs with the shared secret
+var victimMessage = []byte("crazy flamboyant for the rap enjoyment")
+
+// PointOracle hands a public point to a party with a static key, and returns
+// the message it answered with along with its MAC under the shared point.
+type PointOracle func(pt *Point) ([]byte, []byte, error)
+
+// UOracle is a PointOracle for a party using u coordinates only.
+type UOracle func(u *big.Int) ([]byte, []byte, error)
+
+// Victim answers every public point with a message MAC'd with the shared
+// point (see Point.Bytes), keeping the same key throughout.
+type Victim struct {
+	*ECDH
+
+	// validate makes the victim check public points first
+	validate bool
+}
+
+// NewVictim makes a victim with a fresh key on curve.
+func NewVictim(curve *Curve, validate bool) (*Victim, error) {
+	e, err := NewECDH(curve)
+	if err != nil {
+		return nil, err
+	}
+	return &Victim{e, validate}, nil
+}
+
+// Respond can be used as a PointOracle.
+func (v *Victim) Respond(pt *Point) ([]byte, []byte, error) {
+	var shared *Point
+	if v.validate {
+		var err error
+		if shared, err = v.ValidSessionKey(pt); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		shared = v.SessionKey(pt)
+	}
+	return victimMessage, SessionMAC(shared.Bytes(v.Curve.Size()), victimMessage), nil
+}
+
+// XVictim is a Victim using u coordinates only, MACing with the shared u.
+type XVictim struct {
+	*XECDH
+	validate bool
+}
+
+// NewXVictim makes a victim with a fresh key on curve.
+func NewXVictim(curve *MontgomeryCurve, validate bool) (*XVictim, error) {
+	e, err := NewXECDH(curve)
+	if err != nil {
+		return nil, err
+	}
+	return &XVictim{e, validate}, nil
+}
+
+// Respond can be used as a UOracle.
+func (v *XVictim) Respond(u *big.Int) ([]byte, []byte, error) {
+	var shared *big.Int
+	if v.validate {
+		var err error
+		if shared, err = v.ValidSessionKey(u); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		shared = v.SessionKey(u)
+	}
+	return victimMessage, SessionMAC(shared.Bytes(), victimMessage), nil
+}
+
+// randomField picks a number mod p.
+func randomField(p *big.Int) *big.Int {
+	x, err := rand.Int(rand.Reader, p)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+// InvalidCurveAttack recovers the key of a party which does not check that
+// the points it gets are on curve. Every prime r below bound dividing the
+// order of one of the invalid curves gets a point of order r sent over, and
+// the shared point can only be one of r multiples of it, which gives the
+// key mod r. The residues are combined with CRT until they cover curve.N,
+// and the key is returned along with the modulus it is known mod. If the
+// modulus is not above curve.N, only part of the key is known.
+func InvalidCurveAttack(curve *Curve, invalid []InvalidCurve, oracle PointOracle, bound int64) (*big.Int, *big.Int, error) {
+	var residues, moduli []*big.Int
+	used := make(map[int64]bool)
+	covered := big.NewInt(1)
+
+	for _, ic := range invalid {
+		bad := curve.WithB(ic.B)
+		for _, r := range mtsn.SmallFactors(ic.Order, bound) {
+			if covered.Cmp(curve.N) > 0 {
+				break
+			}
+			if used[r.Int64()] {
+				continue
+			}
+
+			pt := invalidPointOfOrder(bad, ic.Order, r)
+			msg, mac, err := oracle(pt)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Oracle refused point of order %v: %v", r, err)
+			}
+
+			residue, err := pointLog(bad, pt, r, msg, mac)
+			if err != nil {
+				return nil, nil, err
+			}
+			residues = append(residues, residue)
+			moduli = append(moduli, r)
+			used[r.Int64()] = true
+			covered.Mul(covered, r)
+		}
+	}
+
+	if len(moduli) == 0 {
+		return nil, nil, fmt.Errorf("No factors below %d to attack", bound)
+	}
+	return mtsn.CRT(residues, moduli)
+}
+
+// invalidPointOfOrder finds a point of order r, a prime factor of order, on
+// the curve c which has order points. When r divides order more than once
+// the group may not be cyclic, so every factor of r is taken out of order
+// before multiplying back up to a point of order exactly r.
+func invalidPointOfOrder(c *Curve, order *big.Int, r *big.Int) *Point {
+	cofactor := new(big.Int).Set(order)
+	mod := new(big.Int)
+	for mod.Mod(cofactor, r).Sign() == 0 {
+		cofactor.Div(cofactor, r)
+	}
+
+	for {
+		pt, ok := c.PointAt(randomField(c.P))
+		if !ok {
+			continue
+		}
+		small := c.ScalarMult(pt, cofactor)
+		if small.IsInfinity() {
+			continue
+		}
+		for next := c.ScalarMult(small, r); !next.IsInfinity(); next = c.ScalarMult(small, r) {
+			small = next
+		}
+		return small
+	}
+}
+
+// pointLog finds k below r such that msg MAC'd with k * pt gives mac.
+func pointLog(c *Curve, pt *Point, r *big.Int, msg []byte, mac []byte) (*big.Int, error) {
+	multiple := Infinity
+	for k := int64(0); k < r.Int64(); k++ {
+		if bytes.Equal(SessionMAC(multiple.Bytes(c.Size()), msg), mac) {
+			return big.NewInt(k), nil
+		}
+		multiple = c.Add(multiple, pt)
+	}
+	return nil, fmt.Errorf("No multiple of the point of order %v matches the MAC", r)
+}
+
+// TwistAttack recovers the key of a party using u coordinates only, which
+// does not check that the u it gets is on curve rather than its twist, and
+// whose public u coordinate is public. For every odd prime r below bound
+// dividing the order of the twist, a u of order r gets sent over, and
+// trying its multiples gives the key mod r, up to its sign since k * u and
+// -k * u share a u coordinate.
+//
+// The signs are lined up as the residues get combined: once the key is known
+// to be c or -c mod m, and s or -s mod r, a u of order m * r tells apart
+// which of the combinations of c with s or -s is right. That leaves the key
+// as x or -x mod m, and the rest of it is found with a kangaroo (see
+// twistKangaroo), which takes about sqrt(curve.N / m) steps.
+//
+// The key is only ever known up to its sign mod curve.N, which makes no
+// difference to u coordinates, so the key returned may be curve.N minus the
+// party's.
+//
+// ctx and budget bound the kangaroo like they do for mtsn.Kangaroo, which
+// matters when bound leaves curve.N / m large: each of its (up to four)
+// runs gives up after budget steps, unless budget is 0.
+func TwistAttack(ctx context.Context, curve *MontgomeryCurve, public *big.Int, oracle UOracle, bound int64, budget int64) (*big.Int, error) {
+	twistOrder := curve.TwistOrder()
+
+	var x, m *big.Int
+	var factors []*big.Int
+	for _, r := range mtsn.SmallFactors(twistOrder, bound) {
+		if r.Bit(0) == 0 {
+			continue
+		}
+		if m != nil && m.Cmp(curve.N) > 0 {
+			break
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		u := twistPointOfOrder(curve, twistOrder, []*big.Int{r})
+		msg, mac, err := oracle(u)
+		if err != nil {
+			return nil, fmt.Errorf("Oracle refused u of order %v: %v", r, err)
+		}
+		matches := func(shared *big.Int) bool {
+			return bytes.Equal(SessionMAC(shared.Bytes(), msg), mac)
+		}
+
+		// The key could be 0 mod r, which leaves the point at infinity
+		s, found := int64(0), matches(new(big.Int))
+		if !found {
+			s, found = curve.multiples(u, r.Int64()/2, matches)
+		}
+		if !found {
+			return nil, fmt.Errorf("No multiple of the u of order %v matches the MAC", r)
+		}
+
+		factors = append(factors, r)
+		if m == nil {
+			x, m = big.NewInt(s), r
+			continue
+		}
+		x, m, err = twistCombine(curve, twistOrder, oracle, x, m, big.NewInt(s), factors)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if m == nil {
+		return nil, fmt.Errorf("No odd factors below %d to attack", bound)
+	}
+	return twistKangaroo(ctx, curve, public, x, m, budget)
+}
+
+// twistKangaroo finds the key behind public, knowing that it is x or -x mod
+// m. Kangaroos need to add points, so the search happens on the Weierstrass
+// form of curve, where k * G is Q or -Q for Q a point with u coordinate
+// public. For each sign s of x and each T of Q and -Q, k = s + m * y gives
+// y * (m * G) = T - s * G, with y below curve.N / m.
+func twistKangaroo(ctx context.Context, curve *MontgomeryCurve, public *big.Int, x, m *big.Int, budget int64) (*big.Int, error) {
+	w := curve.Weierstrass()
+	q, ok := w.PointAt(curve.weierstrassX(public))
+	if !ok {
+		return nil, fmt.Errorf("Public u = %v is not on %s", public, curve.Name)
+	}
+	step := w.ScalarMult(w.G, m)
+
+	for _, s := range []*big.Int{x, new(big.Int).Sub(m, x)} {
+		top := new(big.Int).Sub(curve.N, s)
+		top.Sub(top, mtsn.Big.One)
+		if top.Sign() < 0 {
+			continue
+		}
+		top.Div(top, m)
+
+		for _, t := range []*Point{q, w.Neg(q)} {
+			target := w.Add(t, w.Neg(w.ScalarMult(w.G, s)))
+			y, err := mtsn.KangarooIn[*Point](ctx, pointGroup{w}, step, target, mtsn.Big.Zero, top, budget)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				continue
+			}
+			return y.Add(y.Mul(y, m), s), nil
+		}
+	}
+	return nil, fmt.Errorf("No key is +-%v mod %v", x, m)
+}
+
+// pointGroup is the group of points of a curve, for mtsn.KangarooIn.
+type pointGroup struct {
+	c *Curve
+}
+
+func (g pointGroup) Exp(pt *Point, k *big.Int) *Point {
+	return g.c.ScalarMult(pt, k)
+}
+
+func (g pointGroup) Mul(p1, p2 *Point) *Point {
+	return g.c.Add(p1, p2)
+}
+
+func (g pointGroup) Equal(p1, p2 *Point) bool {
+	return p1.Equal(p2)
+}
+
+func (g pointGroup) Label(pt *Point) uint64 {
+	if pt.IsInfinity() {
+		return 0
+	}
+	return pt.X.Uint64()
+}
+
+// twistCombine works out which of x + s or x - s (combined with CRT) is the
+// key up to sign mod m * r, by asking oracle about a u of order m * r. The
+// last of factors is r, and the others multiply to m.
+func twistCombine(curve *MontgomeryCurve, twistOrder *big.Int, oracle UOracle, x, m, s *big.Int, factors []*big.Int) (*big.Int, *big.Int, error) {
+	r := factors[len(factors)-1]
+	plus, mr, err := mtsn.CRT([]*big.Int{x, s}, []*big.Int{m, r})
+	if err != nil {
+		return nil, nil, err
+	}
+	minus, _, err := mtsn.CRT([]*big.Int{x, new(big.Int).Sub(r, s)}, []*big.Int{m, r})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u := twistPointOfOrder(curve, twistOrder, factors)
+	msg, mac, err := oracle(u)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Oracle refused u of order %v: %v", mr, err)
+	}
+	for _, candidate := range []*big.Int{plus, minus} {
+		shared := curve.Ladder(u, candidate)
+		if bytes.Equal(SessionMAC(shared.Bytes(), msg), mac) {
+			return candidate, mr, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("Neither sign fits for the u of order %v", mr)
+}
+
+// twistPointOfOrder finds a u on the twist of curve whose order is exactly
+// the product of factors, distinct primes dividing twistOrder.
+func twistPointOfOrder(curve *MontgomeryCurve, twistOrder *big.Int, factors []*big.Int) *big.Int {
+	order := big.NewInt(1)
+	for _, f := range factors {
+		order.Mul(order, f)
+	}
+	cofactor := new(big.Int).Div(twistOrder, order)
+	for {
+		u := randomField(curve.P)
+		if curve.OnCurve(u) {
+			continue
+		}
+		x, z := curve.ladder(u, cofactor)
+		if z.Sign() == 0 {
+			continue
+		}
+		small := curve.affine(x, z)
+
+		// Every prime factor has to be needed to get to infinity
+		full := true
+		for _, f := range factors {
+			if _, z := curve.ladder(small, new(big.Int).Div(order, f)); z.Sign() == 0 {
+				full = false
+				break
+			}
+		}
+		if full {
+			return small
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package ec does elliptic curve arithmetic over big.Int, slowly and without
+// any care for side channels, so that weak curves and careless key
+// exchanges can be played with.
+package ec
+
+import (
+	"fmt"
+	"math/big"
+	"mtsn"
+)
+
+// Point is an affine point on a short Weierstrass curve. The point at
+// infinity has nil coordinates. Points are never changed once made.
+type Point struct {
+	X, Y *big.Int
+}
+
+// Infinity is the point at infinity, the identity of every curve.
+var Infinity = &Point{}
+
+// IsInfinity says whether pt is the point at infinity.
+func (pt *Point) IsInfinity() bool {
+	return pt.X == nil
+}
+
+// Equal says whether pt and other are the same point.
+func (pt *Point) Equal(other *Point) bool {
+	if pt.IsInfinity() || other.IsInfinity() {
+		return pt.IsInfinity() && other.IsInfinity()
+	}
+	return pt.X.Cmp(other.X) == 0 && pt.Y.Cmp(other.Y) == 0
+}
+
+// Bytes encodes pt as its coordinates one after the other, each size bytes
+// long. The point at infinity is empty.
+func (pt *Point) Bytes(size int) []byte {
+	if pt.IsInfinity() {
+		return []byte{}
+	}
+	out := make([]byte, 2*size)
+	pt.X.FillBytes(out[:size])
+	pt.Y.FillBytes(out[size:])
+	return out
+}
+
+func (pt *Point) String() string {
+	if pt.IsInfinity() {
+		return "(infinity)"
+	}
+	return fmt.Sprintf("(%v, %v)", pt.X, pt.Y)
+}
+
+// Curve is the short Weierstrass curve y^2 = x^3 + A*x + B over the integers
+// mod the prime P, with base point G of prime order N. The whole curve has
+// N * Cofactor points.
+type Curve struct {
+	Name     string
+	P        *big.Int
+	A, B     *big.Int
+	G        *Point
+	N        *big.Int
+	Cofactor *big.Int
+}
+
+// WithB gives the curve with the same P and A as c but a different B, and
+// nothing known about its points. The addition formulas never look at B,
+// so arithmetic done for c works just as well on it.
+func (c *Curve) WithB(b *big.Int) *Curve {
+	return &Curve{Name: fmt.Sprintf("%s with b = %v", c.Name, b), P: c.P, A: c.A, B: b}
+}
+
+// Size is how many bytes it takes to write down a coordinate.
+func (c *Curve) Size() int {
+	return (c.P.BitLen() + 7) / 8
+}
+
+// rhs works out x^3 + A*x + B.
+func (c *Curve) rhs(x *big.Int) *big.Int {
+	out := new(big.Int).Mul(x, x)
+	out.Add(out, c.A)
+	out.Mul(out, x)
+	out.Add(out, c.B)
+	return out.Mod(out, c.P)
+}
+
+// IsOnCurve checks that pt is the point at infinity, or has coordinates in
+// range satisfying the curve equation.
+func (c *Curve) IsOnCurve(pt *Point) bool {
+	if pt.IsInfinity() {
+		return true
+	}
+	if pt.X.Sign() < 0 || pt.X.Cmp(c.P) >= 0 || pt.Y.Sign() < 0 || pt.Y.Cmp(c.P) >= 0 {
+		return false
+	}
+	y2 := new(big.Int).Mul(pt.Y, pt.Y)
+	return y2.Mod(y2, c.P).Cmp(c.rhs(pt.X)) == 0
+}
+
+// Validate checks everything a public key received from someone else needs:
+// it has to be on the curve, not the point at infinity, and in the subgroup
+// generated by G.
+func (c *Curve) Validate(pt *Point) error {
+	if pt.IsInfinity() {
+		return fmt.Errorf("Point at infinity is not a valid public key")
+	}
+	if !c.IsOnCurve(pt) {
+		return fmt.Errorf("Point %v is not on %s", pt, c.Name)
+	}
+	if c.N != nil && !c.ScalarMult(pt, c.N).IsInfinity() {
+		return fmt.Errorf("Point %v is not in the subgroup of order %v", pt, c.N)
+	}
+	return nil
+}
+
+// PointAt finds a point with the given x, if there is one. Of the two, it
+// returns the one sqrt picks.
+func (c *Curve) PointAt(x *big.Int) (*Point, bool) {
+	x = new(big.Int).Mod(x, c.P)
+	y := new(big.Int).ModSqrt(c.rhs(x), c.P)
+	if y == nil {
+		return nil, false
+	}
+	return &Point{x, y}, true
+}
+
+// Neg is -pt.
+func (c *Curve) Neg(pt *Point) *Point {
+	if pt.IsInfinity() {
+		return pt
+	}
+	y := new(big.Int).Neg(pt.Y)
+	return &Point{new(big.Int).Set(pt.X), y.Mod(y, c.P)}
+}
+
+// Add is p1 + p2.
+func (c *Curve) Add(p1, p2 *Point) *Point {
+	if p1.IsInfinity() {
+		return p2
+	}
+	if p2.IsInfinity() {
+		return p1
+	}
+	if p1.X.Cmp(p2.X) == 0 {
+		sum := new(big.Int).Add(p1.Y, p2.Y)
+		if sum.Mod(sum, c.P).Sign() == 0 {
+			return Infinity
+		}
+		return c.Double(p1)
+	}
+
+	// The slope of the line through both points
+	num := new(big.Int).Sub(p2.Y, p1.Y)
+	den := new(big.Int).Sub(p2.X, p1.X)
+	return c.chord(p1, p2, num, den)
+}
+
+// Double is 2 * pt.
+func (c *Curve) Double(pt *Point) *Point {
+	if pt.IsInfinity() || pt.Y.Sign() == 0 {
+		return Infinity
+	}
+
+	// The slope of the tangent, (3x^2 + A) / 2y
+	num := new(big.Int).Mul(pt.X, pt.X)
+	num.Mul(num, mtsn.Big.Three)
+	num.Add(num, c.A)
+	den := new(big.Int).Lsh(pt.Y, 1)
+	return c.chord(pt, pt, num, den)
+}
+
+// chord finds the third point on the line through p1 and p2 with slope
+// num / den, and reflects it.
+func (c *Curve) chord(p1, p2 *Point, num, den *big.Int) *Point {
+	den.Mod(den, c.P)
+	inv := new(big.Int).ModInverse(den, c.P)
+	slope := num.Mul(num, inv)
+	slope.Mod(slope, c.P)
+
+	x := new(big.Int).Mul(slope, slope)
+	x.Sub(x, p1.X)
+	x.Sub(x, p2.X)
+	x.Mod(x, c.P)
+
+	y := new(big.Int).Sub(p1.X, x)
+	y.Mul(y, slope)
+	y.Sub(y, p1.Y)
+	y.Mod(y, c.P)
+	return &Point{x, y}
+}
+
+// ScalarMult is k * pt, for k >= 0, with a Montgomery ladder.
+func (c *Curve) ScalarMult(pt *Point, k *big.Int) *Point {
+	r0, r1 := Infinity, pt
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		if k.Bit(i) == 0 {
+			r1 = c.Add(r0, r1)
+			r0 = c.Double(r0)
+		} else {
+			r0 = c.Add(r0, r1)
+			r1 = c.Double(r1)
+		}
+	}
+	return r0
+}
+
+// ScalarBaseMult is k * G.
+func (c *Curve) ScalarBaseMult(k *big.Int) *Point {
+	return c.ScalarMult(c.G, k)
+}
@@ -0,0 +1,48 @@
+package ec
+
+import (
+	"math/big"
+	"mtsn"
+)
+
+// Weak128 is a 128 bit curve, y^2 = x^3 - 95051*x + 11279326, small enough
+// that its invalid curves and twist are quick to attack.
+var Weak128 = &Curve{
+	Name: "weak128",
+	P:    mtsn.DecBigInt("233970423115425145524320034830162017933"),
+	A:    big.NewInt(-95051),
+	B:    big.NewInt(11279326),
+	G: &Point{
+		big.NewInt(182),
+		mtsn.DecBigInt("85518893674295321206118380980485522083"),
+	},
+	N:        mtsn.DecBigInt("29246302889428143187362802287225875743"),
+	Cofactor: big.NewInt(8),
+}
+
+// Weak128Montgomery is Weak128 written as v^2 = u^3 + 534*u^2 + u, where
+// u = x - 178.
+var Weak128Montgomery = &MontgomeryCurve{
+	Name:     "weak128 (Montgomery)",
+	P:        Weak128.P,
+	A:        big.NewInt(534),
+	B:        big.NewInt(1),
+	U:        big.NewInt(4),
+	N:        Weak128.N,
+	Cofactor: big.NewInt(8),
+}
+
+// InvalidCurve is a curve sharing P and A with some other curve, along with
+// how many points it has.
+type InvalidCurve struct {
+	B     *big.Int
+	Order *big.Int
+}
+
+// Weak128InvalidCurves are curves with the same P and A as Weak128, whose
+// orders have enough small factors between them to recover a Weak128 key.
+var Weak128InvalidCurves = []InvalidCurve{
+	{big.NewInt(210), mtsn.DecBigInt("233970423115425145550826547352470124412")},
+	{big.NewInt(504), mtsn.DecBigInt("233970423115425145544350131142039591210")},
+	{big.NewInt(727), mtsn.DecBigInt("233970423115425145545378039958152057148")},
+}
@@ -0,0 +1,298 @@
+package ec
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCurveArithmetic(t *testing.T) {
+	c := Weak128
+	if !c.IsOnCurve(c.G) {
+		t.Fatalf("G is not on the curve")
+	}
+	if err := c.Validate(c.G); err != nil {
+		t.Errorf("G does not validate: %v", err)
+	}
+	if !c.ScalarBaseMult(c.N).IsInfinity() {
+		t.Errorf("N * G is not the point at infinity")
+	}
+
+	sum := Infinity
+	for i := int64(0); i < 10; i++ {
+		if !sum.Equal(c.ScalarBaseMult(big.NewInt(i))) {
+			t.Errorf("Adding G %d times does not give %d * G", i, i)
+		}
+		if !c.IsOnCurve(sum) {
+			t.Errorf("%d * G is not on the curve", i)
+		}
+		sum = c.Add(sum, c.G)
+	}
+
+	a, _ := rand.Int(rand.Reader, c.N)
+	b, _ := rand.Int(rand.Reader, c.N)
+	left := c.Add(c.ScalarBaseMult(a), c.ScalarBaseMult(b))
+	right := c.ScalarBaseMult(new(big.Int).Add(a, b))
+	if !left.Equal(right) {
+		t.Errorf("a * G + b * G is not (a + b) * G")
+	}
+	if !c.Add(c.ScalarBaseMult(a), c.Neg(c.ScalarBaseMult(a))).IsInfinity() {
+		t.Errorf("P - P is not the point at infinity")
+	}
+
+	if err := c.Validate(Infinity); err == nil {
+		t.Errorf("The point at infinity should not validate")
+	}
+	if err := c.Validate(&Point{c.G.X, new(big.Int).Add(c.G.Y, big.NewInt(1))}); err == nil {
+		t.Errorf("A point off the curve should not validate")
+	}
+	for _, ic := range Weak128InvalidCurves {
+		bad := c.WithB(ic.B)
+		pt, ok := bad.PointAt(big.NewInt(5))
+		for x := int64(6); !ok; x++ {
+			pt, ok = bad.PointAt(big.NewInt(x))
+		}
+		if !bad.ScalarMult(pt, ic.Order).IsInfinity() {
+			t.Errorf("Order of the curve with b = %v is wrong", ic.B)
+		}
+		if err := c.Validate(pt); err == nil {
+			t.Errorf("Point on the curve with b = %v should not validate", ic.B)
+		}
+	}
+}
+
+func TestMontgomeryLadder(t *testing.T) {
+	m := Weak128Montgomery
+	offset := big.NewInt(178)
+	for i := 0; i < 5; i++ {
+		k, _ := rand.Int(rand.Reader, m.N)
+		u := new(big.Int).Sub(Weak128.ScalarBaseMult(k).X, offset)
+		u.Mod(u, m.P)
+		if got := m.Ladder(m.U, k); got.Cmp(u) != 0 {
+			t.Errorf("Ladder gave u = %v for k = %v, want %v", got, k, u)
+		}
+	}
+
+	if m.Ladder(m.U, m.N).Sign() != 0 {
+		t.Errorf("N * U is not the point at infinity")
+	}
+	if err := m.Validate(m.U); err != nil {
+		t.Errorf("U does not validate: %v", err)
+	}
+
+	var twist *big.Int
+	for u := int64(2); twist == nil; u++ {
+		if !m.OnCurve(big.NewInt(u)) {
+			twist = big.NewInt(u)
+		}
+	}
+	if m.Ladder(twist, m.TwistOrder()).Sign() != 0 {
+		t.Errorf("Twist order does not take u = %v to infinity", twist)
+	}
+	if err := m.Validate(twist); err == nil {
+		t.Errorf("u = %v on the twist should not validate", twist)
+	}
+
+	found, ok := m.multiples(m.U, 20, func(u *big.Int) bool {
+		return u.Cmp(m.Ladder(m.U, big.NewInt(13))) == 0
+	})
+	if !ok || found != 13 {
+		t.Errorf("Found multiple %d (%v), want 13", found, ok)
+	}
+}
+
+func TestWeierstrass(t *testing.T) {
+	w := Weak128Montgomery.Weierstrass()
+	a := new(big.Int).Mod(Weak128.A, w.P)
+	if w.A.Cmp(a) != 0 || w.B.Cmp(Weak128.B) != 0 {
+		t.Errorf("Got a = %v and b = %v, want the ones of weak128", w.A, w.B)
+	}
+	if w.G == nil || w.G.X.Cmp(Weak128.G.X) != 0 {
+		t.Errorf("Base point %v does not have the x of weak128's", w.G)
+	}
+	if !w.ScalarBaseMult(w.N).IsInfinity() {
+		t.Errorf("N * G is not the point at infinity")
+	}
+
+	w = tiny.Weierstrass()
+	k := big.NewInt(1234567)
+	pt := w.ScalarBaseMult(k)
+	if pt.X.Cmp(tiny.weierstrassX(tiny.Ladder(tiny.U, k))) != 0 {
+		t.Errorf("Ladder and Weierstrass form do not agree on k * U")
+	}
+}
+
+func TestECDH(t *testing.T) {
+	alice, err := NewECDH(Weak128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := NewECDH(Weak128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := alice.ValidSessionKey(bob.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !shared.Equal(bob.SessionKey(alice.Public)) {
+		t.Errorf("Alice and Bob do not agree on a shared point")
+	}
+
+	xAlice, err := NewXECDH(Weak128Montgomery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xBob, err := NewXECDH(Weak128Montgomery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := xAlice.ValidSessionKey(xBob.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Cmp(xBob.SessionKey(xAlice.Public)) != 0 {
+		t.Errorf("Alice and Bob do not agree on a shared u")
+	}
+}
+
+func TestInvalidCurveAttack(t *testing.T) {
+	victim, err := NewVictim(Weak128, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, m, err := InvalidCurveAttack(Weak128, Weak128InvalidCurves, victim.Respond, 1<<16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Cmp(Weak128.N) <= 0 {
+		t.Fatalf("Only learnt the key mod %v", m)
+	}
+	if key.Cmp(victim.Private) != 0 {
+		t.Errorf("Recovered key %v, want %v", key, victim.Private)
+	}
+
+	careful, err := NewVictim(Weak128, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := InvalidCurveAttack(Weak128, Weak128InvalidCurves, careful.Respond, 1<<16); err == nil {
+		t.Errorf("A victim validating points should stop the attack")
+	}
+}
+
+// tiny is a 40 bit curve, v^2 = u^3 + 525*u^2 + u, whose twist has
+// 8 * 3 * 107 * 439 * 551519 points. Below 1024, the twist attack only gets
+// the key mod 140919, and has to find the other 18 bits with a kangaroo.
+var tiny = &MontgomeryCurve{
+	Name:     "tiny",
+	P:        big.NewInt(621755677639),
+	A:        big.NewInt(525),
+	B:        big.NewInt(1),
+	U:        big.NewInt(202232823514),
+	N:        big.NewInt(77719413449),
+	Cofactor: big.NewInt(8),
+}
+
+func TestTwistAttack(t *testing.T) {
+	if tiny.Ladder(tiny.U, tiny.N).Sign() != 0 {
+		t.Fatalf("N * U is not the point at infinity")
+	}
+
+	for i := 0; i < 5; i++ {
+		victim, err := NewXVictim(tiny, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := TwistAttack(context.Background(), tiny, victim.Public, victim.Respond, 1<<10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key.Cmp(victim.Private) != 0 && new(big.Int).Add(key, victim.Private).Cmp(tiny.N) != 0 {
+			t.Errorf("Got key %v, want +-%v", key, victim.Private)
+		}
+	}
+
+	careful, err := NewXVictim(tiny, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TwistAttack(context.Background(), tiny, careful.Public, careful.Respond, 1<<10, 0); err == nil {
+		t.Errorf("A victim validating u should stop the attack")
+	}
+
+	// Leaving the kangaroo too many steps to take
+	victim, err := NewXVictim(tiny, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TwistAttack(context.Background(), tiny, victim.Public, victim.Respond, 1<<10, 10); err == nil {
+		t.Errorf("Kangaroo found the key within 10 steps")
+	}
+
+	// Only the factor 3, which leaves the kangaroo far too much to cover
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := TwistAttack(ctx, tiny, victim.Public, victim.Respond, 1<<3, 0); err != context.DeadlineExceeded {
+		t.Errorf("Attack gave %v instead of stopping at the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Took %v to notice the deadline", elapsed)
+	}
+}
+
+func TestECDSA(t *testing.T) {
+	key, err := NewECDSA(Weak128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("hi mom")
+	sig, err := key.Sign(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Verify(Weak128, key.Public, msg, sig) {
+		t.Errorf("Signature does not verify")
+	}
+	if Verify(Weak128, key.Public, []byte("hi dad"), sig) {
+		t.Errorf("Signature verifies for another message")
+	}
+	other, _ := NewECDSA(Weak128)
+	if Verify(Weak128, other.Public, msg, sig) {
+		t.Errorf("Signature verifies for another key")
+	}
+	if Verify(Weak128, key.Public, msg, &Signature{sig.R, new(big.Int).Add(sig.S, big.NewInt(1))}) {
+		t.Errorf("Tampered signature verifies")
+	}
+}
+
+func TestHNPAttack(t *testing.T) {
+	key, err := NewECDSA(Weak128)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, leak := range []NonceLeak{{Bits: 16, Top: false}, {Bits: 16, Top: true}, {Bits: 10, Top: false}} {
+		count := SignaturesNeeded(Weak128.N.BitLen(), leak)
+		sigs := make([]*LeakySignature, count)
+		for i := range sigs {
+			sigs[i], err = key.SignLeaky([]byte(fmt.Sprintf("message %d", i)), leak)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		d, err := HNPAttack(Weak128, key.Public, sigs, leak)
+		if err != nil {
+			t.Errorf("%+v: %v", leak, err)
+			continue
+		}
+		if d.Cmp(key.Private) != 0 {
+			t.Errorf("%+v: recovered %v instead of %v", leak, d, key.Private)
+		}
+	}
+}
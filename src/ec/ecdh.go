@@ -0,0 +1,84 @@
+package ec
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"mtsn"
+)
+
+// SessionMAC MACs msg with HMAC-SHA256, keyed with a shared secret.
+func SessionMAC(secret []byte, msg []byte) []byte {
+	return mtsn.HMACSum(sha256.New, secret, msg)
+}
+
+// randomScalar picks a private key between 1 and n - 1.
+func randomScalar(n *big.Int) (*big.Int, error) {
+	k, err := rand.Int(rand.Reader, new(big.Int).Sub(n, mtsn.Big.One))
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, mtsn.Big.One), nil
+}
+
+// ECDH is one party of an elliptic curve Diffie-Hellman key exchange.
+type ECDH struct {
+	Curve   *Curve
+	Private *big.Int
+	Public  *Point
+}
+
+// NewECDH makes a key pair on curve.
+func NewECDH(curve *Curve) (*ECDH, error) {
+	private, err := randomScalar(curve.N)
+	if err != nil {
+		return nil, err
+	}
+	return &ECDH{curve, private, curve.ScalarBaseMult(private)}, nil
+}
+
+// SessionKey works out the shared point for otherPublic, without checking it.
+func (e *ECDH) SessionKey(otherPublic *Point) *Point {
+	return e.Curve.ScalarMult(otherPublic, e.Private)
+}
+
+// ValidSessionKey works out the shared point like SessionKey, once
+// otherPublic has passed Curve.Validate.
+func (e *ECDH) ValidSessionKey(otherPublic *Point) (*Point, error) {
+	if err := e.Curve.Validate(otherPublic); err != nil {
+		return nil, err
+	}
+	return e.SessionKey(otherPublic), nil
+}
+
+// XECDH is one party of a key exchange on a Montgomery curve, using u
+// coordinates only.
+type XECDH struct {
+	Curve   *MontgomeryCurve
+	Private *big.Int
+	Public  *big.Int
+}
+
+// NewXECDH makes a key pair on curve.
+func NewXECDH(curve *MontgomeryCurve) (*XECDH, error) {
+	private, err := randomScalar(curve.N)
+	if err != nil {
+		return nil, err
+	}
+	return &XECDH{curve, private, curve.Ladder(curve.U, private)}, nil
+}
+
+// SessionKey works out the shared u coordinate for otherPublic, without
+// checking it.
+func (e *XECDH) SessionKey(otherPublic *big.Int) *big.Int {
+	return e.Curve.Ladder(otherPublic, e.Private)
+}
+
+// ValidSessionKey works out the shared u coordinate like SessionKey, once
+// otherPublic has passed Curve.Validate.
+func (e *XECDH) ValidSessionKey(otherPublic *big.Int) (*big.Int, error) {
+	if err := e.Curve.Validate(otherPublic); err != nil {
+		return nil, err
+	}
+	return e.SessionKey(otherPublic), nil
+}
@@ -0,0 +1,96 @@
+package ec
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// Signature is an ECDSA signature.
+type Signature struct {
+	R, S *big.Int
+}
+
+// ECDSA is an ECDSA key pair.
+type ECDSA struct {
+	Curve   *Curve
+	Private *big.Int
+	Public  *Point
+}
+
+// NewECDSA makes a key pair on curve.
+func NewECDSA(curve *Curve) (*ECDSA, error) {
+	private, err := randomScalar(curve.N)
+	if err != nil {
+		return nil, err
+	}
+	return &ECDSA{curve, private, curve.ScalarBaseMult(private)}, nil
+}
+
+// HashToInt hashes msg with SHA-256, keeping as many of the leftmost bits as
+// n has.
+func HashToInt(msg []byte, n *big.Int) *big.Int {
+	digest := sha256.Sum256(msg)
+	h := new(big.Int).SetBytes(digest[:])
+	if excess := len(digest)*8 - n.BitLen(); excess > 0 {
+		h.Rsh(h, uint(excess))
+	}
+	return h
+}
+
+// Sign signs msg with a fresh random nonce.
+func (e *ECDSA) Sign(msg []byte) (*Signature, error) {
+	for {
+		k, err := randomScalar(e.Curve.N)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := e.SignWithNonce(msg, k)
+		if err == nil {
+			return sig, nil
+		}
+	}
+}
+
+// SignWithNonce signs msg using the nonce k, which had better be random and
+// secret. It fails for the rare k which give a zero r or s.
+func (e *ECDSA) SignWithNonce(msg []byte, k *big.Int) (*Signature, error) {
+	n := e.Curve.N
+	r := new(big.Int).Mod(e.Curve.ScalarBaseMult(k).X, n)
+	if r.Sign() == 0 {
+		return nil, fmt.Errorf("Nonce gives r = 0")
+	}
+
+	kInv := new(big.Int).ModInverse(k, n)
+	if kInv == nil {
+		return nil, fmt.Errorf("Nonce %v is not invertible mod %v", k, n)
+	}
+	s := new(big.Int).Mul(r, e.Private)
+	s.Add(s, HashToInt(msg, n))
+	s.Mul(s, kInv)
+	s.Mod(s, n)
+	if s.Sign() == 0 {
+		return nil, fmt.Errorf("Nonce gives s = 0")
+	}
+	return &Signature{r, s}, nil
+}
+
+// Verify checks sig over msg against the public key public on curve.
+func Verify(curve *Curve, public *Point, msg []byte, sig *Signature) bool {
+	n := curve.N
+	if sig.R.Sign() <= 0 || sig.R.Cmp(n) >= 0 || sig.S.Sign() <= 0 || sig.S.Cmp(n) >= 0 {
+		return false
+	}
+
+	w := new(big.Int).ModInverse(sig.S, n)
+	u1 := new(big.Int).Mul(HashToInt(msg, n), w)
+	u1.Mod(u1, n)
+	u2 := new(big.Int).Mul(sig.R, w)
+	u2.Mod(u2, n)
+
+	pt := curve.Add(curve.ScalarBaseMult(u1), curve.ScalarMult(public, u2))
+	if pt.IsInfinity() {
+		return false
+	}
+	return new(big.Int).Mod(pt.X, n).Cmp(sig.R) == 0
+}
@@ -0,0 +1,143 @@
+package ec
+
+import (
+	"fmt"
+	"math/big"
+	"mtsn"
+)
+
+// NonceLeak says which bits of every nonce an attacker gets to see: the top
+// Bits bits (counting from the bit length of N) if Top is set, the bottom
+// Bits bits otherwise.
+type NonceLeak struct {
+	Bits int
+	Top  bool
+}
+
+// LeakySignature is a signature of Msg along with the leaked bits of its
+// nonce.
+type LeakySignature struct {
+	Msg   []byte
+	Sig   *Signature
+	Known *big.Int
+}
+
+// SignLeaky signs msg, and lets out the bits of the nonce leak describes.
+func (e *ECDSA) SignLeaky(msg []byte, leak NonceLeak) (*LeakySignature, error) {
+	for {
+		k, err := randomScalar(e.Curve.N)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := e.SignWithNonce(msg, k)
+		if err != nil {
+			continue
+		}
+
+		var known *big.Int
+		if leak.Top {
+			known = new(big.Int).Rsh(k, uint(e.Curve.N.BitLen()-leak.Bits))
+		} else {
+			known = new(big.Int).Mod(k, new(big.Int).Lsh(mtsn.Big.One, uint(leak.Bits)))
+		}
+		return &LeakySignature{msg, sig, known}, nil
+	}
+}
+
+// SignaturesNeeded is about how many leaky signatures HNPAttack wants for a
+// curve whose order has nBits bits: enough for the leaked bits to add up to
+// the key with some room to spare.
+func SignaturesNeeded(nBits int, leak NonceLeak) int {
+	return (nBits*4/3)/leak.Bits + 2
+}
+
+// HNPAttack recovers the private key behind public from signatures whose
+// nonces leak some bits, as the hidden number problem.
+//
+// Every signature gives k = s^-1 * (h + r * d) mod N. Taking out the known
+// bits of k leaves b_i = t_i * d + u_i mod N, where b_i is below
+// B = 2**(bits of N - leak.Bits). The lattice spanned by the rows
+//
+//	N                          (one row per signature)
+//	   ...
+//	         N
+//	t_1 ... t_n  B/N
+//	u_1 ... u_n        B
+//
+// then has (b_1, ..., b_n, d*B/N, B) in it, which is unusually short, so LLL
+// tends to find it. Everything is scaled by N to keep to integers.
+func HNPAttack(curve *Curve, public *Point, sigs []*LeakySignature, leak NonceLeak) (*big.Int, error) {
+	n := curve.N
+	nBits := n.BitLen()
+	if leak.Bits <= 0 || leak.Bits >= nBits {
+		return nil, fmt.Errorf("Cannot work with %d leaked bits out of %d", leak.Bits, nBits)
+	}
+	bound := new(big.Int).Lsh(mtsn.Big.One, uint(nBits-leak.Bits))
+	half := new(big.Int).Rsh(bound, 1)
+
+	count := len(sigs)
+	basis := make([][]*big.Int, count+2)
+	for i := range basis {
+		basis[i] = make([]*big.Int, count+2)
+		for j := range basis[i] {
+			basis[i][j] = new(big.Int)
+		}
+	}
+
+	nn := new(big.Int).Mul(n, n)
+	for i, leaky := range sigs {
+		t, u, err := hnpCoefficients(n, leaky, leak)
+		if err != nil {
+			return nil, err
+		}
+		// Centre b_i on 0, which makes the target vector shorter still
+		u.Sub(u, half)
+
+		basis[i][i].Set(nn)
+		basis[count][i].Mul(t, n)
+		basis[count+1][i].Mul(u, n)
+	}
+	basis[count][count].Set(bound)
+	basis[count+1][count+1].Mul(bound, n)
+
+	for _, row := range mtsn.LLL(basis, nil) {
+		last := row[count+1]
+		if new(big.Int).Abs(last).Cmp(basis[count+1][count+1]) != 0 {
+			continue
+		}
+
+		d := new(big.Int).Div(row[count], bound)
+		if last.Sign() < 0 {
+			d.Neg(d)
+		}
+		d.Mod(d, n)
+		if curve.ScalarBaseMult(d).Equal(public) {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("Lattice of %d signatures did not give the key", count)
+}
+
+// hnpCoefficients works out t and u such that the unknown part of the
+// nonce of leaky is t * d + u mod N.
+func hnpCoefficients(n *big.Int, leaky *LeakySignature, leak NonceLeak) (*big.Int, *big.Int, error) {
+	sInv := new(big.Int).ModInverse(leaky.Sig.S, n)
+	if sInv == nil {
+		return nil, nil, fmt.Errorf("s = %v is not invertible", leaky.Sig.S)
+	}
+	t := new(big.Int).Mul(sInv, leaky.Sig.R)
+	u := new(big.Int).Mul(sInv, HashToInt(leaky.Msg, n))
+
+	if leak.Top {
+		// k = known * 2**(bits of N - leak.Bits) + b
+		known := new(big.Int).Lsh(leaky.Known, uint(n.BitLen()-leak.Bits))
+		u.Sub(u, known)
+	} else {
+		// k = known + 2**leak.Bits * b
+		inv := new(big.Int).ModInverse(new(big.Int).Lsh(mtsn.Big.One, uint(leak.Bits)), n)
+		u.Sub(u, leaky.Known)
+		u.Mul(u, inv)
+		t.Mul(t, inv)
+	}
+	return t.Mod(t, n), u.Mod(u, n), nil
+}
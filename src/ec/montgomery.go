@@ -0,0 +1,205 @@
+package ec
+
+import (
+	"fmt"
+	"math/big"
+	"mtsn"
+)
+
+// MontgomeryCurve is the curve B*v^2 = u^3 + A*u^2 + u over the integers mod
+// the prime P, used through u coordinates only. The base point U has prime
+// order N, and the whole curve has N * Cofactor points.
+type MontgomeryCurve struct {
+	Name     string
+	P        *big.Int
+	A, B     *big.Int
+	U        *big.Int
+	N        *big.Int
+	Cofactor *big.Int
+}
+
+// Order is the number of points on the curve.
+func (m *MontgomeryCurve) Order() *big.Int {
+	return new(big.Int).Mul(m.N, m.Cofactor)
+}
+
+// TwistOrder is the number of points on the quadratic twist of the curve,
+// which is where a u coordinate not on the curve ends up. Both orders add
+// up to 2 * (P + 1).
+func (m *MontgomeryCurve) TwistOrder() *big.Int {
+	order := new(big.Int).Add(m.P, mtsn.Big.One)
+	order.Lsh(order, 1)
+	return order.Sub(order, m.Order())
+}
+
+// Weierstrass gives the same curve in short Weierstrass form, where points
+// can be added rather than just multiplied: (u, v) becomes
+// ((u + A/3) / B, v / B). Its base point is one of the two points whose u
+// coordinate is U.
+func (m *MontgomeryCurve) Weierstrass() *Curve {
+	p := m.P
+	invB := new(big.Int).ModInverse(m.B, p)
+	inv3 := new(big.Int).ModInverse(big.NewInt(3), p)
+
+	// a = (3 - A^2) / (3 * B^2)
+	a := new(big.Int).Mul(m.A, m.A)
+	a.Sub(big.NewInt(3), a)
+	a.Mul(a, inv3)
+	a.Mul(a, invB)
+	a.Mul(a, invB)
+	a.Mod(a, p)
+
+	// b = (2 * A^3 - 9 * A) / (27 * B^3)
+	b := new(big.Int).Mul(m.A, m.A)
+	b.Lsh(b, 1)
+	b.Sub(b, big.NewInt(9))
+	b.Mul(b, m.A)
+	for i := 0; i < 3; i++ {
+		b.Mul(b, inv3)
+		b.Mul(b, invB)
+	}
+	b.Mod(b, p)
+
+	w := &Curve{Name: m.Name + " (Weierstrass)", P: p, A: a, B: b, N: m.N, Cofactor: m.Cofactor}
+	w.G, _ = w.PointAt(m.weierstrassX(m.U))
+	return w
+}
+
+// weierstrassX is the x coordinate of the points with u coordinate u, once
+// the curve is in Weierstrass form.
+func (m *MontgomeryCurve) weierstrassX(u *big.Int) *big.Int {
+	x := new(big.Int).ModInverse(big.NewInt(3), m.P)
+	x.Mul(x, m.A)
+	x.Add(x, u)
+	x.Mul(x, new(big.Int).ModInverse(m.B, m.P))
+	return x.Mod(x, m.P)
+}
+
+// OnCurve says whether u is the u coordinate of a point on the curve rather
+// than on its twist.
+func (m *MontgomeryCurve) OnCurve(u *big.Int) bool {
+	// v^2 = (u^3 + A*u^2 + u) / B needs to have a solution
+	rhs := new(big.Int).Add(u, m.A)
+	rhs.Mul(rhs, u)
+	rhs.Add(rhs, mtsn.Big.One)
+	rhs.Mul(rhs, u)
+	inv := new(big.Int).ModInverse(m.B, m.P)
+	rhs.Mul(rhs, inv)
+	rhs.Mod(rhs, m.P)
+	return big.Jacobi(rhs, m.P) >= 0
+}
+
+// Validate checks that u is a sensible public key: in range, on the curve
+// and not the twist, and in the subgroup generated by U.
+func (m *MontgomeryCurve) Validate(u *big.Int) error {
+	if u.Sign() <= 0 || u.Cmp(m.P) >= 0 {
+		return fmt.Errorf("u = %v is out of range", u)
+	}
+	if !m.OnCurve(u) {
+		return fmt.Errorf("u = %v is on the twist of %s", u, m.Name)
+	}
+	if _, z := m.ladder(u, m.N); z.Sign() != 0 {
+		return fmt.Errorf("u = %v is not in the subgroup of order %v", u, m.N)
+	}
+	return nil
+}
+
+// Ladder works out the u coordinate of k times the point with u coordinate
+// u, which is the same for both points with that u. It works for points on
+// the twist too. The point at infinity comes out as 0.
+func (m *MontgomeryCurve) Ladder(u *big.Int, k *big.Int) *big.Int {
+	x, z := m.ladder(u, k)
+	return m.affine(x, z)
+}
+
+// affine turns the projective u coordinate (x : z) into a plain one.
+func (m *MontgomeryCurve) affine(x, z *big.Int) *big.Int {
+	if z.Sign() == 0 {
+		return new(big.Int)
+	}
+	u := new(big.Int).ModInverse(z, m.P)
+	u.Mul(u, x)
+	return u.Mod(u, m.P)
+}
+
+// ladder is the Montgomery ladder, keeping (x2 : z2) = n * u and
+// (x3 : z3) = (n + 1) * u as the bits of k are taken in from the top.
+func (m *MontgomeryCurve) ladder(u *big.Int, k *big.Int) (*big.Int, *big.Int) {
+	p := m.P
+	x2, z2 := big.NewInt(1), big.NewInt(0)
+	x3, z3 := new(big.Int).Mod(u, p), big.NewInt(1)
+
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		if k.Bit(i) == 1 {
+			x2, x3 = x3, x2
+			z2, z3 = z3, z2
+		}
+		x3, z3 = m.differentialAdd(x2, z2, x3, z3, u, mtsn.Big.One)
+		x2, z2 = m.double(x2, z2)
+		if k.Bit(i) == 1 {
+			x2, x3 = x3, x2
+			z2, z3 = z3, z2
+		}
+	}
+	return x2, z2
+}
+
+// differentialAdd is (x1 : z1) + (x2 : z2), given their difference
+// (xd : zd).
+func (m *MontgomeryCurve) differentialAdd(x1, z1, x2, z2, xd, zd *big.Int) (*big.Int, *big.Int) {
+	p := m.P
+	t1 := new(big.Int).Mul(x1, x2)
+	t1.Sub(t1, new(big.Int).Mul(z1, z2))
+	t1.Mul(t1, t1)
+	t1.Mul(t1, zd)
+	t1.Mod(t1, p)
+
+	t2 := new(big.Int).Mul(x1, z2)
+	t2.Sub(t2, new(big.Int).Mul(z1, x2))
+	t2.Mul(t2, t2)
+	t2.Mul(t2, xd)
+	t2.Mod(t2, p)
+	return t1, t2
+}
+
+// double is 2 * (x : z).
+func (m *MontgomeryCurve) double(x, z *big.Int) (*big.Int, *big.Int) {
+	p := m.P
+	xx := new(big.Int).Mul(x, x)
+	zz := new(big.Int).Mul(z, z)
+	xz := new(big.Int).Mul(x, z)
+
+	x2 := new(big.Int).Sub(xx, zz)
+	x2.Mul(x2, x2)
+	x2.Mod(x2, p)
+
+	z2 := new(big.Int).Mul(m.A, xz)
+	z2.Add(z2, xx)
+	z2.Add(z2, zz)
+	z2.Mul(z2, xz)
+	z2.Lsh(z2, 2)
+	z2.Mod(z2, p)
+	return x2, z2
+}
+
+// multiples calls found with the u coordinates of 1 * u, 2 * u, ... up to
+// count * u, until found returns true, and returns which multiple that was.
+// Going from one to the next only takes a differential addition and an
+// inversion, rather than a whole ladder.
+func (m *MontgomeryCurve) multiples(u *big.Int, count int64, found func(*big.Int) bool) (int64, bool) {
+	one := mtsn.Big.One
+	prevX, prevZ := new(big.Int).Mod(u, m.P), big.NewInt(1)
+	if count >= 1 && found(m.affine(prevX, prevZ)) {
+		return 1, true
+	}
+	x, z := m.double(prevX, prevZ)
+	for j := int64(2); j <= count; j++ {
+		if found(m.affine(x, z)) {
+			return j, true
+		}
+		// (j + 1) * u = j * u + u, and their difference is (j - 1) * u
+		nextX, nextZ := m.differentialAdd(x, z, u, one, prevX, prevZ)
+		prevX, prevZ, x, z = x, z, nextX, nextZ
+	}
+	return 0, false
+}
@@ -0,0 +1,69 @@
+// Package hashhacks describes what the sha1hacks, md4hacks, md5hacks,
+// sha256hacks and sha512hacks packages expose about the inside of their
+// hashes, so that attacks on Merkle–Damgård hashes can be written once.
+package hashhacks
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+// HashState is a hash whose internal state can be looked at and driven one
+// block at a time.
+type HashState interface {
+	hash.Hash
+
+	// ChainingValue returns the chaining value after the last full block,
+	// encoded the same way as a digest. For a message of a whole number of
+	// blocks with its padding included, this is the digest.
+	ChainingValue() []byte
+
+	// Length returns the number of bytes written so far.
+	Length() uint64
+
+	// Padding returns the bytes the hash appends to a message of msgLen
+	// bytes before its last compression.
+	Padding(msgLen uint64) []byte
+
+	// Compress runs the compression function on a single block, which must
+	// be BlockSize bytes long. It panics if there are bytes waiting in the
+	// buffer from an earlier Write.
+	Compress(block []byte)
+}
+
+// Resumer builds a HashState that picks up from prevDigest, as if length
+// bytes (padding included) had already been written to it. Every hacks
+// package has one called HackedHasher.
+type Resumer func(prevDigest []byte, length uint64) HashState
+
+// Padding builds Merkle–Damgård padding for a message of msgLen bytes: a 1
+// bit, 0 bits until there is just enough space left in the block, and then
+// the length of the message in bits on lengthSize bytes.
+func Padding(msgLen uint64, blockSize int, lengthSize int, littleEndian bool) []byte {
+	zeros := blockSize - lengthSize - 1 - int(msgLen%uint64(blockSize))
+	zeros = (zeros + blockSize) % blockSize
+
+	padding := make([]byte, 1+zeros+lengthSize)
+	padding[0] = 0x80
+
+	// Only the bottom 64 bits of the length are ever filled, which matters
+	// for SHA-512 and its 128 bit length.
+	bits := padding[len(padding)-8:]
+	if littleEndian {
+		binary.LittleEndian.PutUint64(bits, msgLen<<3)
+	} else {
+		binary.BigEndian.PutUint64(bits, msgLen<<3)
+	}
+	return padding
+}
+
+// CheckCompress panics unless block can be handed to the compression
+// function of a hash with the given block size, with nx bytes buffered.
+func CheckCompress(block []byte, blockSize int, nx int) {
+	if len(block) != blockSize {
+		panic("Compress needs exactly one block")
+	}
+	if nx != 0 {
+		panic("Compress called with bytes left in the buffer")
+	}
+}
@@ -0,0 +1,89 @@
+package hashhacks_test
+
+import (
+	"bytes"
+	"hashhacks"
+	"md4hacks"
+	"md5hacks"
+	"sha1hacks"
+	"sha256hacks"
+	"sha512hacks"
+	"testing"
+)
+
+var states = map[string]func() hashhacks.HashState{
+	"SHA-1":   sha1hacks.NewState,
+	"MD4":     md4hacks.NewState,
+	"MD5":     md5hacks.NewState,
+	"SHA-256": sha256hacks.NewState,
+	"SHA-512": sha512hacks.NewState,
+}
+
+var resumers = map[string]hashhacks.Resumer{
+	"SHA-1":   sha1hacks.HackedHasher,
+	"MD4":     md4hacks.HackedHasher,
+	"MD5":     md5hacks.HackedHasher,
+	"SHA-256": sha256hacks.HackedHasher,
+	"SHA-512": sha512hacks.HackedHasher,
+}
+
+func TestPadding(t *testing.T) {
+	for name, newState := range states {
+		h := newState()
+		for msgLen := uint64(0); msgLen < uint64(3*h.BlockSize()); msgLen++ {
+			padding := h.Padding(msgLen)
+			if (msgLen+uint64(len(padding)))%uint64(h.BlockSize()) != 0 {
+				t.Errorf("%s padding for %d bytes is %d bytes, which does not end on a block", name, msgLen, len(padding))
+			}
+			if len(padding) > h.BlockSize()+16 {
+				t.Errorf("%s padding for %d bytes is too long (%d bytes)", name, msgLen, len(padding))
+			}
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	for name, newState := range states {
+		h := newState()
+		msg := []byte("Some message which is not quite the length of a block")
+		padded := append(append([]byte{}, msg...), h.Padding(uint64(len(msg)))...)
+
+		// Compressing the padded message by hand ends on its digest
+		for i := 0; i < len(padded); i += h.BlockSize() {
+			h.Compress(padded[i : i+h.BlockSize()])
+		}
+		if h.Length() != uint64(len(padded)) {
+			t.Errorf("%s processed %d bytes, expected %d", name, h.Length(), len(padded))
+		}
+
+		expected := newState()
+		expected.Write(msg)
+		if !bytes.Equal(h.ChainingValue(), expected.Sum(nil)) {
+			t.Errorf("%s chaining value %x does not match digest %x", name, h.ChainingValue(), expected.Sum(nil))
+		}
+
+		// And resuming from that chaining value gives the same state back
+		resumed := resumers[name](h.ChainingValue(), h.Length())
+		resumed.Write(msg)
+		h.Write(msg)
+		if !bytes.Equal(resumed.Sum(nil), h.Sum(nil)) {
+			t.Errorf("%s resumed hash %x does not match %x", name, resumed.Sum(nil), h.Sum(nil))
+		}
+	}
+}
+
+func TestCompressPanics(t *testing.T) {
+	for name, newState := range states {
+		h := newState()
+		h.Write([]byte("a"))
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s Compress did not panic with a partial block buffered", name)
+				}
+			}()
+			h.Compress(make([]byte, h.BlockSize()))
+		}()
+	}
+}
@@ -0,0 +1,60 @@
+// Package hashtest holds the checks shared by the tests of the hash hack
+// packages. It imports testing, so only tests should import it.
+package hashtest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"hash"
+	"hashhacks"
+	"testing"
+)
+
+// Golden is a digest in hex, followed by the message it is the digest of.
+type Golden [2]string
+
+// CheckGolden checks that sum, and a hash from newHash written to one byte
+// at a time, give the expected digest for every message of golden.
+func CheckGolden(t testing.TB, newHash func() hash.Hash, sum func([]byte) []byte, golden []Golden) {
+	t.Helper()
+	for _, g := range golden {
+		out, in := g[0], g[1]
+		if s := hex.EncodeToString(sum([]byte(in))); s != out {
+			t.Errorf("Sum(%q) = %s want %s", in, s, out)
+		}
+
+		h := newHash()
+		for i := 0; i < len(in); i++ {
+			h.Write([]byte(in[i : i+1]))
+		}
+		if s := hex.EncodeToString(h.Sum(nil)); s != out {
+			t.Errorf("Byte by byte (%q) = %s want %s", in, s, out)
+		}
+	}
+}
+
+// CheckResume checks that resume picks up a hash from newState where it was
+// left, by comparing it with reference hashing the whole message.
+func CheckResume(t testing.TB, newState func() hashhacks.HashState, resume hashhacks.Resumer, reference func() hash.Hash) {
+	t.Helper()
+	prev := newState()
+
+	// A whole number of blocks, so the padding is exactly one more block
+	first := bytes.Repeat([]byte("a"), 3*prev.BlockSize())
+	second := []byte("and then some more text, long enough to go past one block of the hash at least once or twice")
+	padding := prev.Padding(uint64(len(first)))
+
+	h := reference()
+	h.Write(first)
+	h.Write(padding)
+	h.Write(second)
+	expected := h.Sum(nil)
+
+	prev.Write(first)
+	resumed := resume(prev.Sum(nil), uint64(len(first)+len(padding)))
+	resumed.Write(second)
+
+	if actual := resumed.Sum(nil); !bytes.Equal(expected, actual) {
+		t.Errorf("Resumed hash gave %x, expected %x", actual, expected)
+	}
+}
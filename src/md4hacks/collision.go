@@ -0,0 +1,299 @@
+package md4hacks
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Kinds of sufficient conditions on a bit of the MD4 state
+const (
+	BIT_ZERO = iota
+	BIT_ONE
+	BIT_EQUAL
+	BIT_DIFFERENT
+)
+
+// WangCondition is one of the sufficient conditions from Wang et al.'s
+// "Cryptanalysis of the Hash Functions MD4 and RIPEMD". Var and Other name
+// the chaining variables the way the paper does ("a1", "d1", ..., "b0" for
+// the IV), and Bit counts from 1 like in the paper. Other is only used by
+// BIT_EQUAL and BIT_DIFFERENT.
+type WangCondition struct {
+	Var   string
+	Bit   uint
+	Kind  int
+	Other string
+}
+
+// WangConditions are the sufficient conditions for M and WangDifferential(M)
+// to collide, as listed in table 6 of the paper. The round 1 and first two
+// round 2 variables get fixed by message modification; the rest are left to
+// chance.
+var WangConditions = []WangCondition{
+	{"a1", 7, BIT_EQUAL, "b0"},
+	{"d1", 7, BIT_ZERO, ""}, {"d1", 8, BIT_EQUAL, "a1"}, {"d1", 11, BIT_EQUAL, "a1"},
+	{"c1", 7, BIT_ONE, ""}, {"c1", 8, BIT_ONE, ""}, {"c1", 11, BIT_ZERO, ""}, {"c1", 26, BIT_EQUAL, "d1"},
+	{"b1", 7, BIT_ONE, ""}, {"b1", 8, BIT_ZERO, ""}, {"b1", 11, BIT_ZERO, ""}, {"b1", 26, BIT_ZERO, ""},
+	{"a2", 8, BIT_ONE, ""}, {"a2", 11, BIT_ONE, ""}, {"a2", 26, BIT_ZERO, ""}, {"a2", 14, BIT_EQUAL, "b1"},
+	{"d2", 14, BIT_ZERO, ""}, {"d2", 19, BIT_EQUAL, "a2"}, {"d2", 20, BIT_EQUAL, "a2"},
+	{"d2", 21, BIT_EQUAL, "a2"}, {"d2", 22, BIT_EQUAL, "a2"}, {"d2", 26, BIT_ONE, ""},
+	{"c2", 13, BIT_EQUAL, "d2"}, {"c2", 14, BIT_ZERO, ""}, {"c2", 15, BIT_EQUAL, "d2"},
+	{"c2", 19, BIT_ZERO, ""}, {"c2", 20, BIT_ZERO, ""}, {"c2", 21, BIT_ONE, ""}, {"c2", 22, BIT_ZERO, ""},
+	{"b2", 13, BIT_ONE, ""}, {"b2", 14, BIT_ONE, ""}, {"b2", 15, BIT_ZERO, ""}, {"b2", 17, BIT_EQUAL, "c2"},
+	{"b2", 19, BIT_ZERO, ""}, {"b2", 20, BIT_ZERO, ""}, {"b2", 21, BIT_ZERO, ""}, {"b2", 22, BIT_ZERO, ""},
+	{"a3", 13, BIT_ONE, ""}, {"a3", 14, BIT_ONE, ""}, {"a3", 15, BIT_ONE, ""}, {"a3", 17, BIT_ZERO, ""},
+	{"a3", 19, BIT_ZERO, ""}, {"a3", 20, BIT_ZERO, ""}, {"a3", 21, BIT_ZERO, ""}, {"a3", 22, BIT_ONE, ""},
+	{"a3", 23, BIT_EQUAL, "b2"}, {"a3", 26, BIT_EQUAL, "b2"},
+	{"d3", 13, BIT_ONE, ""}, {"d3", 14, BIT_ONE, ""}, {"d3", 15, BIT_ONE, ""}, {"d3", 17, BIT_ZERO, ""},
+	{"d3", 20, BIT_ZERO, ""}, {"d3", 21, BIT_ONE, ""}, {"d3", 22, BIT_ONE, ""}, {"d3", 23, BIT_ZERO, ""},
+	{"d3", 26, BIT_ONE, ""}, {"d3", 30, BIT_EQUAL, "a3"},
+	{"c3", 17, BIT_ONE, ""}, {"c3", 20, BIT_ZERO, ""}, {"c3", 21, BIT_ZERO, ""}, {"c3", 22, BIT_ZERO, ""},
+	{"c3", 23, BIT_ZERO, ""}, {"c3", 26, BIT_ZERO, ""}, {"c3", 30, BIT_ONE, ""}, {"c3", 32, BIT_EQUAL, "d3"},
+	{"b3", 20, BIT_ZERO, ""}, {"b3", 21, BIT_ONE, ""}, {"b3", 22, BIT_ONE, ""}, {"b3", 23, BIT_EQUAL, "c3"},
+	{"b3", 26, BIT_ONE, ""}, {"b3", 30, BIT_ZERO, ""}, {"b3", 32, BIT_ZERO, ""},
+	{"a4", 23, BIT_ZERO, ""}, {"a4", 26, BIT_ZERO, ""}, {"a4", 27, BIT_EQUAL, "b3"},
+	{"a4", 29, BIT_EQUAL, "b3"}, {"a4", 30, BIT_ONE, ""}, {"a4", 32, BIT_ZERO, ""},
+	{"d4", 23, BIT_ZERO, ""}, {"d4", 26, BIT_ZERO, ""}, {"d4", 27, BIT_ONE, ""},
+	{"d4", 29, BIT_ONE, ""}, {"d4", 30, BIT_ZERO, ""}, {"d4", 32, BIT_ONE, ""},
+	{"c4", 19, BIT_EQUAL, "d4"}, {"c4", 23, BIT_ONE, ""}, {"c4", 26, BIT_ONE, ""},
+	{"c4", 27, BIT_ZERO, ""}, {"c4", 29, BIT_ZERO, ""}, {"c4", 30, BIT_ZERO, ""},
+	{"b4", 19, BIT_ZERO, ""}, {"b4", 26, BIT_EQUAL, "c4"}, {"b4", 27, BIT_ONE, ""},
+	{"b4", 29, BIT_ONE, ""}, {"b4", 30, BIT_ZERO, ""},
+
+	// Round 2
+	{"a5", 19, BIT_EQUAL, "c4"}, {"a5", 26, BIT_ONE, ""}, {"a5", 27, BIT_ZERO, ""},
+	{"a5", 29, BIT_ONE, ""}, {"a5", 32, BIT_ONE, ""},
+	{"d5", 19, BIT_EQUAL, "a5"}, {"d5", 26, BIT_EQUAL, "b4"}, {"d5", 27, BIT_EQUAL, "b4"},
+	{"d5", 29, BIT_EQUAL, "b4"}, {"d5", 32, BIT_EQUAL, "b4"},
+	{"c5", 26, BIT_EQUAL, "d5"}, {"c5", 27, BIT_EQUAL, "d5"}, {"c5", 29, BIT_EQUAL, "d5"},
+	{"c5", 30, BIT_EQUAL, "d5"}, {"c5", 32, BIT_EQUAL, "d5"},
+	{"b5", 29, BIT_EQUAL, "c5"}, {"b5", 30, BIT_ONE, ""}, {"b5", 32, BIT_ZERO, ""},
+	{"a6", 29, BIT_ONE, ""}, {"a6", 32, BIT_ONE, ""},
+	{"d6", 29, BIT_EQUAL, "b5"},
+	{"c6", 29, BIT_EQUAL, "d6"}, {"c6", 30, BIT_DIFFERENT, "d6"}, {"c6", 32, BIT_DIFFERENT, "d6"},
+
+	// Round 3
+	{"b9", 32, BIT_ONE, ""},
+	{"a10", 32, BIT_ONE, ""},
+}
+
+// wangState holds every chaining variable MD4 goes through on one block, in
+// the order they are computed: a0, d0, c0, b0 (the IV), a1, d1, c1, b1, a2...
+// Step k of the compression function writes vars[k+4].
+type wangState struct {
+	m    [16]uint32
+	vars [52]uint32
+}
+
+// varIndex turns a name like "c4" into an index in wangState.vars.
+func varIndex(name string) int {
+	round := 0
+	for _, digit := range name[1:] {
+		round = round*10 + int(digit-'0')
+	}
+	return 4*round + strings.IndexByte("adcb", name[0])
+}
+
+// stepWord and stepShift give the message word and rotation used in step k.
+func stepWord(k int) uint {
+	switch {
+	case k < 16:
+		return uint(k)
+	case k < 32:
+		return xIndex2[k-16]
+	}
+	return xIndex3[k-32]
+}
+
+func stepShift(k int) uint {
+	switch {
+	case k < 16:
+		return shift1[k%4]
+	case k < 32:
+		return shift2[k%4]
+	}
+	return shift3[k%4]
+}
+
+// stepMix is everything step k adds to its first variable, except for the
+// message word.
+func (w *wangState) stepMix(k int) uint32 {
+	b, c, d := w.vars[k+3], w.vars[k+2], w.vars[k+1]
+	switch {
+	case k < 16:
+		return w.vars[k] + (((c ^ d) & b) ^ d)
+	case k < 32:
+		return w.vars[k] + ((b & c) | (b & d) | (c & d)) + 0x5a827999
+	}
+	return w.vars[k] + (b ^ c ^ d) + 0x6ed9eba1
+}
+
+// step runs step k of the compression function.
+func (w *wangState) step(k int) {
+	s := stepShift(k)
+	v := w.stepMix(k) + w.m[stepWord(k)]
+	w.vars[k+4] = v<<s | v>>(32-s)
+}
+
+// solve picks the message word for step k so that it lands on the value
+// already in vars[k+4].
+func (w *wangState) solve(k int) {
+	s := stepShift(k)
+	v := w.vars[k+4]
+	w.m[stepWord(k)] = (v>>s | v<<(32-s)) - w.stepMix(k)
+}
+
+// run computes all the chaining variables from the message.
+func (w *wangState) run() {
+	for k := 0; k < 48; k++ {
+		w.step(k)
+	}
+}
+
+// fix forces vars[index] to meet every condition on it, and returns how
+// many needed changing.
+func (w *wangState) fix(index int) int {
+	fixed := 0
+	for _, cond := range WangConditions {
+		if varIndex(cond.Var) != index || w.meets(cond) {
+			continue
+		}
+		w.vars[index] ^= 1 << (cond.Bit - 1)
+		fixed++
+	}
+	return fixed
+}
+
+// meets checks a single condition.
+func (w *wangState) meets(cond WangCondition) bool {
+	bit := (w.vars[varIndex(cond.Var)] >> (cond.Bit - 1)) & 1
+	switch cond.Kind {
+	case BIT_ZERO:
+		return bit == 0
+	case BIT_ONE:
+		return bit == 1
+	}
+	other := (w.vars[varIndex(cond.Other)] >> (cond.Bit - 1)) & 1
+	if cond.Kind == BIT_EQUAL {
+		return bit == other
+	}
+	return bit != other
+}
+
+// newWangState sets up the state for block, starting from the MD4 IV.
+func newWangState(block []byte) *wangState {
+	w := new(wangState)
+	w.vars[0], w.vars[1], w.vars[2], w.vars[3] = _Init0, _Init3, _Init2, _Init1
+	for i := range w.m {
+		w.m[i] = binary.LittleEndian.Uint32(block[4*i:])
+	}
+	w.run()
+	return w
+}
+
+func (w *wangState) block() []byte {
+	block := make([]byte, BlockSize)
+	for i, word := range w.m {
+		binary.LittleEndian.PutUint32(block[4*i:], word)
+	}
+	return block
+}
+
+// WangConditionsMet counts how many of WangConditions block meets, when
+// hashed from the MD4 IV.
+func WangConditionsMet(block []byte) int {
+	w := newWangState(block)
+	met := 0
+	for _, cond := range WangConditions {
+		if w.meets(cond) {
+			met++
+		}
+	}
+	return met
+}
+
+// WangDifferential returns the block which collides with block if block
+// meets all of WangConditions.
+func WangDifferential(block []byte) []byte {
+	w := newWangState(block)
+	w.m[1] += 1 << 31
+	w.m[2] += 1<<31 - 1<<28
+	w.m[12] -= 1 << 16
+	return w.block()
+}
+
+// WangModify applies Wang's message modifications to block: all of the
+// round 1 conditions get forced by picking message words, and then a5 and d5
+// get fixed by going back and changing round 1 without breaking it (apart
+// from the odd carry into a1 or a2).
+func WangModify(block []byte) []byte {
+	w := newWangState(block)
+	w.modifyRound1()
+	w.modifyRound2()
+	return w.block()
+}
+
+// modifyRound1 picks every round 1 message word so that its chaining
+// variable meets its conditions.
+func (w *wangState) modifyRound1() {
+	for k := 0; k < 16; k++ {
+		w.step(k)
+		w.fix(k + 4)
+		w.solve(k)
+	}
+}
+
+// modifyRound2 fixes a5 and d5 once round 1 is done.
+func (w *wangState) modifyRound2() {
+	// a5 comes from m0, which changes a1, so pick m1 to m4 again to keep
+	// d1, c1, b1 and a2
+	w.step(16)
+	if w.fix(varIndex("a5")) > 0 {
+		w.solve(16)
+		w.step(0)
+		for k := 1; k < 5; k++ {
+			w.solve(k)
+		}
+	}
+
+	// d5 comes from m4, which changes a2, so pick m5 to m8 again
+	w.step(17)
+	if w.fix(varIndex("d5")) > 0 {
+		w.solve(17)
+		w.step(4)
+		for k := 5; k < 9; k++ {
+			w.solve(k)
+		}
+	}
+	w.run()
+}
+
+// WangCollision looks for two different 64 byte blocks with the same MD4
+// hash, using rng for randomness (or a time seeded one if nil). If report is
+// not nil, it gets called with the number of conditions met by every
+// candidate tried.
+func WangCollision(rng *rand.Rand, report func(tries int, met int)) ([]byte, []byte) {
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	block := make([]byte, BlockSize)
+	for tries := 1; ; tries++ {
+		rng.Read(block)
+		m := WangModify(block)
+		if report != nil {
+			report(tries, WangConditionsMet(m))
+		}
+
+		mPrime := WangDifferential(m)
+		h := NewState()
+		h.Compress(m)
+		hPrime := NewState()
+		hPrime.Compress(mPrime)
+		if string(h.ChainingValue()) == string(hPrime.ChainingValue()) {
+			return m, mPrime
+		}
+	}
+}
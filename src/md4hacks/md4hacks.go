@@ -2,15 +2,44 @@ package md4hacks
 
 import (
 	"encoding/binary"
-	"hash"
+	"hashhacks"
 )
 
-func HackedHasher(prevDigest []byte, length int) hash.Hash {
+// NewState returns a new MD4 hash which exposes its internal state.
+func NewState() hashhacks.HashState {
 	d := new(digest)
-	d.len = uint64(length)
+	d.Reset()
+	return d
+}
+
+// HackedHasher returns a hash which picks up where prevDigest left off, as if
+// length bytes (padding included) had already been written to it.
+func HackedHasher(prevDigest []byte, length uint64) hashhacks.HashState {
+	d := new(digest)
+	d.len = length
 
-	for i := 0; i < Size; i +=4 {
-		d.s[i/4] = binary.LittleEndian.Uint32(prevDigest[i:i+4])
+	for i := 0; i < Size; i += 4 {
+		d.s[i/4] = binary.LittleEndian.Uint32(prevDigest[i : i+4])
 	}
 	return d
-}
\ No newline at end of file
+}
+
+func (d *digest) ChainingValue() []byte {
+	value := make([]byte, Size)
+	for i, word := range d.s {
+		binary.LittleEndian.PutUint32(value[i*4:], word)
+	}
+	return value
+}
+
+func (d *digest) Length() uint64 { return d.len }
+
+func (d *digest) Padding(msgLen uint64) []byte {
+	return hashhacks.Padding(msgLen, BlockSize, 8, true)
+}
+
+func (d *digest) Compress(p []byte) {
+	hashhacks.CheckCompress(p, BlockSize, d.nx)
+	_Block(d, p)
+	d.len += BlockSize
+}
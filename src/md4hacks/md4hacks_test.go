@@ -0,0 +1,59 @@
+package md4hacks
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestWangModify(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	block := make([]byte, BlockSize)
+	for i := 0; i < 100; i++ {
+		rng.Read(block)
+
+		// Round 1 modifications force every round 1 condition
+		w := newWangState(block)
+		w.modifyRound1()
+		w.run()
+		for _, cond := range WangConditions {
+			if varIndex(cond.Var) < varIndex("a5") && !w.meets(cond) {
+				t.Errorf("Round 1 modification of %x missed %v", block, cond)
+			}
+		}
+
+		// And a5 and d5 always come out right in the end
+		w = newWangState(WangModify(block))
+		for _, cond := range WangConditions {
+			if (cond.Var == "a5" || cond.Var == "d5") && !w.meets(cond) {
+				t.Errorf("Modification of %x missed %v", block, cond)
+			}
+		}
+	}
+}
+
+func TestWangCollision(t *testing.T) {
+	candidates := 0
+	m, mPrime := WangCollision(rand.New(rand.NewSource(1)), func(tries int, met int) {
+		candidates = tries
+		if met > len(WangConditions) {
+			t.Fatalf("Candidate %d met %d out of %d conditions", tries, met, len(WangConditions))
+		}
+	})
+	t.Logf("Found a collision after %d candidates", candidates)
+
+	if bytes.Equal(m, mPrime) {
+		t.Fatalf("Collision is the same block twice")
+	}
+	if !bytes.Equal(mPrime, WangDifferential(m)) {
+		t.Errorf("Collision does not follow Wang's differential")
+	}
+
+	h := New()
+	h.Write(m)
+	hPrime := New()
+	hPrime.Write(mPrime)
+	if !bytes.Equal(h.Sum(nil), hPrime.Sum(nil)) {
+		t.Errorf("MD4 of %x is %x, but %x for %x", m, h.Sum(nil), hPrime.Sum(nil), mPrime)
+	}
+}
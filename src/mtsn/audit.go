@@ -0,0 +1,282 @@
+package mtsn
+
+import (
+	"math/big"
+)
+
+const (
+	// Number of steps FermatFactor takes away from sqrt(n) before giving up
+	FERMAT_ROUNDS int = 1 << 16
+	// Smoothness bound used by PollardPMinus1
+	POLLARD_BOUND int64 = 1 << 16
+)
+
+// Names of the attacks AuditRSAKeys can break keys with.
+const (
+	ATTACK_BATCH_GCD    = "batch gcd"
+	ATTACK_FERMAT       = "fermat"
+	ATTACK_POLLARD      = "pollard p-1"
+	ATTACK_WIENER       = "wiener"
+	ATTACK_BONEH_DURFEE = "boneh-durfee"
+	// The same modulus shows up more than once in the batch, so whoever
+	// holds one of the keys can decrypt for the others. It cannot be
+	// factored from the batch alone, so P, Q and D are left nil.
+	ATTACK_DUPLICATE = "duplicate modulus"
+)
+
+// RSAAuditResult describes a key broken by AuditRSAKeys. Index is the
+// position of the key in the slice given to AuditRSAKeys.
+type RSAAuditResult struct {
+	Index  int
+	Key    *RSAPublicKey
+	Attack string
+	P      *big.Int
+	Q      *big.Int
+	D      *big.Int
+}
+
+// Private rebuilds the private side of the broken key, or returns nil for
+// results without D, i.e. those for ATTACK_DUPLICATE.
+func (r *RSAAuditResult) Private() *RSA {
+	if r.D == nil {
+		return nil
+	}
+	return &RSA{r.Key.N, r.Key.E, r.D}
+}
+
+// AuditRSAKeys tries to factor (or otherwise find the private exponent of)
+// every key given. It first runs BatchGCD on the whole batch to find keys
+// sharing a prime, and then tries FermatFactor, PollardPMinus1, WienerAttack
+// and BonehDurfeeAttack on each remaining key. It returns one result per
+// broken key, in the same order as keys.
+func AuditRSAKeys(keys []*RSAPublicKey) []*RSAAuditResult {
+	var results []*RSAAuditResult
+
+	moduli := make([]*big.Int, len(keys))
+	for i, key := range keys {
+		moduli[i] = key.N
+	}
+	shared := BatchGCD(moduli)
+
+	for i, key := range keys {
+		var result *RSAAuditResult
+
+		if shared[i].Cmp(Big.One) != 0 {
+			result = sharedResult(keys, i, shared[i])
+		}
+		if result == nil {
+			if p := FermatFactor(key.N, FERMAT_ROUNDS); p != nil {
+				result = factoredResult(key, p, ATTACK_FERMAT)
+			}
+		}
+		if result == nil {
+			if p := PollardPMinus1(key.N, POLLARD_BOUND); p != nil {
+				result = factoredResult(key, p, ATTACK_POLLARD)
+			}
+		}
+		if result == nil {
+			if d, p, q := WienerAttack(key); d != nil {
+				result = &RSAAuditResult{Key: key, Attack: ATTACK_WIENER, P: p, Q: q, D: d}
+			}
+		}
+		if result == nil {
+			if d, p, q := BonehDurfeeAttack(key, BonehDurfeeBound(key)); d != nil {
+				result = &RSAAuditResult{Key: key, Attack: ATTACK_BONEH_DURFEE, P: p, Q: q, D: d}
+			}
+		}
+
+		if result != nil {
+			result.Index = i
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// sharedResult builds the RSAAuditResult for keys[i], given the gcd BatchGCD
+// found for it. When that gcd is the whole modulus, both primes are shared,
+// either each with a different key, which gcds with the other moduli one at
+// a time tell apart, or with a copy of the same modulus.
+func sharedResult(keys []*RSAPublicKey, i int, shared *big.Int) *RSAAuditResult {
+	key := keys[i]
+	if shared.Cmp(key.N) != 0 {
+		return factoredResult(key, shared, ATTACK_BATCH_GCD)
+	}
+
+	g := new(big.Int)
+	for j, other := range keys {
+		if j == i {
+			continue
+		}
+		g.GCD(nil, nil, key.N, other.N)
+		if g.Cmp(Big.One) != 0 && g.Cmp(key.N) != 0 {
+			return factoredResult(key, g, ATTACK_BATCH_GCD)
+		}
+	}
+	return &RSAAuditResult{Key: key, Attack: ATTACK_DUPLICATE}
+}
+
+// factoredResult builds an RSAAuditResult given one of the prime factors of
+// key.N, or returns nil if the private exponent cannot be computed.
+func factoredResult(key *RSAPublicKey, p *big.Int, attack string) *RSAAuditResult {
+	q := new(big.Int).Div(key.N, p)
+	rsa, err := NewRSAFromPrimes(p, q, key.E)
+	if err != nil {
+		return nil
+	}
+	return &RSAAuditResult{Key: key, Attack: attack, P: p, Q: q, D: rsa.d}
+}
+
+// BatchGCD computes, for every modulus, the gcd of that modulus with the
+// product of all the others (using Bernstein's product and remainder
+// trees). A result other than 1 means the modulus shares a prime with
+// another one in the batch.
+func BatchGCD(moduli []*big.Int) []*big.Int {
+	if len(moduli) == 0 {
+		return nil
+	}
+
+	// Build the product tree, leaves first
+	tree := [][]*big.Int{moduli}
+	for level := moduli; len(level) > 1; {
+		next := make([]*big.Int, (len(level)+1)/2)
+		for i := range next {
+			next[i] = new(big.Int).Set(level[2*i])
+			if 2*i+1 < len(level) {
+				next[i].Mul(next[i], level[2*i+1])
+			}
+		}
+		tree = append(tree, next)
+		level = next
+	}
+
+	// Walk back down, keeping product mod n**2 at each node
+	rems := tree[len(tree)-1]
+	for i := len(tree) - 2; i >= 0; i-- {
+		level := tree[i]
+		next := make([]*big.Int, len(level))
+		for j, node := range level {
+			square := new(big.Int).Mul(node, node)
+			next[j] = new(big.Int).Mod(rems[j/2], square)
+		}
+		rems = next
+	}
+
+	output := make([]*big.Int, len(moduli))
+	for i, n := range moduli {
+		quotient := new(big.Int).Div(rems[i], n)
+		output[i] = new(big.Int).GCD(nil, nil, quotient, n)
+	}
+	return output
+}
+
+// FermatFactor looks for a factor of n by walking up from sqrt(n), which
+// works well when both primes are close to each other. It returns nil if
+// nothing is found within rounds steps.
+func FermatFactor(n *big.Int, rounds int) *big.Int {
+	if n.Bit(0) == 0 {
+		return big.NewInt(2)
+	}
+
+	a := new(big.Int).Sqrt(n)
+	if new(big.Int).Mul(a, a).Cmp(n) == 0 {
+		return a
+	}
+	a.Add(a, Big.One)
+
+	b2 := new(big.Int).Mul(a, a)
+	b2.Sub(b2, n)
+	b := new(big.Int)
+	for i := 0; i < rounds; i++ {
+		b.Sqrt(b2)
+		if new(big.Int).Mul(b, b).Cmp(b2) == 0 {
+			return new(big.Int).Sub(a, b)
+		}
+
+		// (a+1)**2 - a**2 = 2a + 1
+		b2.Add(b2, a).Add(b2, a).Add(b2, Big.One)
+		a.Add(a, Big.One)
+	}
+	return nil
+}
+
+// PollardPMinus1 looks for a prime factor p of n where p - 1 only has factors
+// below bound. It returns nil if it cannot find one.
+func PollardPMinus1(n *big.Int, bound int64) *big.Int {
+	a := big.NewInt(2)
+	g := new(big.Int)
+	am1 := new(big.Int)
+
+	for j := int64(2); j <= bound; j++ {
+		a.Exp(a, big.NewInt(j), n)
+
+		// Checking the gcd is expensive, so only do it every so often
+		if j%1024 == 0 || j == bound {
+			am1.Sub(a, Big.One)
+			g.GCD(nil, nil, am1, n)
+			if g.Cmp(n) == 0 {
+				// Overshot, every factor fell out at once
+				return nil
+			}
+			if g.Cmp(Big.One) != 0 {
+				return g
+			}
+		}
+	}
+	return nil
+}
+
+// WienerAttack recovers the private exponent d of key when d is small (below
+// n**(1/4) / 3), by looking for k/d among the convergents of e/n. It returns
+// d and the two primes of n, or nils if the attack does not work.
+func WienerAttack(key *RSAPublicKey) (*big.Int, *big.Int, *big.Int) {
+	// Convergents h/k of e/n, with the previous two kept around
+	hPrev, h := big.NewInt(0), big.NewInt(1)
+	kPrev, k := big.NewInt(1), big.NewInt(0)
+
+	num := new(big.Int).Set(key.E)
+	den := new(big.Int).Set(key.N)
+
+	for den.Sign() != 0 {
+		quotient, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+		num, den = den, rem
+
+		h, hPrev = new(big.Int).Add(new(big.Int).Mul(quotient, h), hPrev), h
+		k, kPrev = new(big.Int).Add(new(big.Int).Mul(quotient, k), kPrev), k
+
+		// The convergent h/k is our guess for k/d
+		if h.Sign() == 0 {
+			continue
+		}
+
+		// phi = (e*d - 1) / k has to be an integer
+		phi := new(big.Int).Mul(key.E, k)
+		phi.Sub(phi, Big.One)
+		phi, phiRem := phi.QuoRem(phi, h, new(big.Int))
+		if phiRem.Sign() != 0 {
+			continue
+		}
+
+		// p and q are the roots of x**2 - (n - phi + 1)x + n
+		sum := new(big.Int).Sub(key.N, phi)
+		sum.Add(sum, Big.One)
+		disc := new(big.Int).Mul(sum, sum)
+		disc.Sub(disc, new(big.Int).Lsh(key.N, 2))
+		if disc.Sign() < 0 {
+			continue
+		}
+		root := new(big.Int).Sqrt(disc)
+		if new(big.Int).Mul(root, root).Cmp(disc) != 0 {
+			continue
+		}
+
+		p := new(big.Int).Add(sum, root)
+		p.Rsh(p, 1)
+		q := new(big.Int).Sub(sum, root)
+		q.Rsh(q, 1)
+		if new(big.Int).Mul(p, q).Cmp(key.N) == 0 {
+			return new(big.Int).Set(k), p, q
+		}
+	}
+	return nil, nil, nil
+}
@@ -0,0 +1,396 @@
+package mtsn
+
+import (
+	"math"
+	"math/big"
+	"sort"
+)
+
+// Lattice parameters for BonehDurfeeAttack: the polynomials are x**i * f**k
+// and y**j * f**k (times e**(BD_M-k)) for k <= BD_M, i <= BD_M-k and
+// 1 <= j <= BD_T. Larger values reach closer to n**0.284, at the cost of a
+// slower LLL.
+const (
+	BD_M = 5
+	BD_T = 1
+)
+
+// biPoly is a polynomial in x and y, where p[i][j] is the coefficient of
+// x**i * y**j.
+type biPoly [][]*big.Int
+
+// newBiPoly returns the zero polynomial with room for x**dx * y**dy.
+func newBiPoly(dx, dy int) biPoly {
+	p := make(biPoly, dx+1)
+	for i := range p {
+		p[i] = make([]*big.Int, dy+1)
+		for j := range p[i] {
+			p[i][j] = new(big.Int)
+		}
+	}
+	return p
+}
+
+// mul returns p * q.
+func (p biPoly) mul(q biPoly) biPoly {
+	res := newBiPoly(len(p)+len(q)-2, len(p[0])+len(q[0])-2)
+	tmp := new(big.Int)
+	for i, row := range p {
+		for j, a := range row {
+			if a.Sign() == 0 {
+				continue
+			}
+			for k, other := range q {
+				for l, b := range other {
+					res[i+k][j+l].Add(res[i+k][j+l], tmp.Mul(a, b))
+				}
+			}
+		}
+	}
+	return res
+}
+
+// shift returns x**dx * y**dy * c * p.
+func (p biPoly) shift(dx, dy int, c *big.Int) biPoly {
+	res := newBiPoly(len(p)+dx-1, len(p[0])+dy-1)
+	for i, row := range p {
+		for j, a := range row {
+			res[i+dx][j+dy].Mul(a, c)
+		}
+	}
+	return res
+}
+
+// degreeX is the degree of p in x, -1 for the zero polynomial.
+func (p biPoly) degreeX() int {
+	for i := len(p) - 1; i >= 0; i-- {
+		for _, a := range p[i] {
+			if a.Sign() != 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// degreeY is the degree of p in y, -1 for the zero polynomial.
+func (p biPoly) degreeY() int {
+	d := -1
+	for _, row := range p {
+		if e := Poly(row).Degree(); e > d {
+			d = e
+		}
+	}
+	return d
+}
+
+// coefsX returns the coefficients of p, taken as a polynomial in x, at y,
+// padded up to x**dx.
+func (p biPoly) coefsX(y *big.Int, dx int) []*big.Int {
+	res := make([]*big.Int, dx+1)
+	for i := range res {
+		res[i] = new(big.Int)
+		if i < len(p) {
+			res[i] = Poly(p[i]).Eval(y)
+		}
+	}
+	return res
+}
+
+// resultantX returns the resultant of p and q with respect to x, which is a
+// polynomial in y. It evaluates the Sylvester determinant at enough values
+// of y and interpolates.
+func resultantX(p, q biPoly) Poly {
+	dp, dq := p.degreeX(), q.degreeX()
+	if dp < 0 || dq < 0 {
+		return Poly{}
+	}
+	if dp == 0 {
+		return Poly(p[0]).Pow(dq, nil)
+	}
+	if dq == 0 {
+		return Poly(q[0]).Pow(dp, nil)
+	}
+
+	degree := dp*q.degreeY() + dq*p.degreeY()
+	size := dp + dq
+	values := make([]*big.Int, degree+1)
+	for v := range values {
+		y := big.NewInt(int64(v))
+		pc, qc := p.coefsX(y, dp), q.coefsX(y, dq)
+		sylvester := make([][]*big.Int, size)
+		for i := range sylvester {
+			sylvester[i] = make([]*big.Int, size)
+			for j := range sylvester[i] {
+				sylvester[i][j] = new(big.Int)
+			}
+		}
+		// dq rows of p's coefficients and dp rows of q's, highest first
+		for i := 0; i < dq; i++ {
+			for j := 0; j <= dp; j++ {
+				sylvester[i][i+j].Set(pc[dp-j])
+			}
+		}
+		for i := 0; i < dp; i++ {
+			for j := 0; j <= dq; j++ {
+				sylvester[dq+i][i+j].Set(qc[dq-j])
+			}
+		}
+		values[v] = determinant(sylvester)
+	}
+	return interpolate(values)
+}
+
+// determinant returns the determinant of the square matrix m, which it
+// overwrites, using Bareiss' fraction free elimination.
+func determinant(m [][]*big.Int) *big.Int {
+	n := len(m)
+	sign := 1
+	prev := big.NewInt(1)
+	tmp := new(big.Int)
+	for k := 0; k < n-1; k++ {
+		if m[k][k].Sign() == 0 {
+			r := k + 1
+			for r < n && m[r][k].Sign() == 0 {
+				r++
+			}
+			if r == n {
+				return new(big.Int)
+			}
+			m[k], m[r] = m[r], m[k]
+			sign = -sign
+		}
+		for i := k + 1; i < n; i++ {
+			for j := k + 1; j < n; j++ {
+				m[i][j].Mul(m[i][j], m[k][k])
+				m[i][j].Sub(m[i][j], tmp.Mul(m[i][k], m[k][j]))
+				m[i][j].Quo(m[i][j], prev)
+			}
+		}
+		prev = m[k][k]
+	}
+	res := new(big.Int).Set(m[n-1][n-1])
+	if sign < 0 {
+		res.Neg(res)
+	}
+	return res
+}
+
+// interpolate returns the polynomial with integer coefficients taking
+// values[i] at x = i, using Newton's divided differences. It returns nil if
+// there is no such polynomial.
+func interpolate(values []*big.Int) Poly {
+	n := len(values)
+	diffs := make([]*big.Rat, n)
+	for i, v := range values {
+		diffs[i] = new(big.Rat).SetInt(v)
+	}
+	for level := 1; level < n; level++ {
+		for i := n - 1; i >= level; i-- {
+			diffs[i].Sub(diffs[i], diffs[i-1])
+			diffs[i].Quo(diffs[i], big.NewRat(int64(level), 1))
+		}
+	}
+
+	// Horner on the Newton form, (...(c[n-1](x - (n-2)) + c[n-2])...)
+	res := []*big.Rat{new(big.Rat).Set(diffs[n-1])}
+	for i := n - 2; i >= 0; i-- {
+		next := make([]*big.Rat, len(res)+1)
+		for j := range next {
+			next[j] = new(big.Rat)
+		}
+		node := big.NewRat(int64(i), 1)
+		for j, c := range res {
+			next[j+1].Add(next[j+1], c)
+			next[j].Sub(next[j], new(big.Rat).Mul(c, node))
+		}
+		next[0].Add(next[0], diffs[i])
+		res = next
+	}
+
+	poly := make(Poly, len(res))
+	for i, c := range res {
+		if !c.IsInt() {
+			return nil
+		}
+		poly[i] = new(big.Int).Set(c.Num())
+	}
+	return poly.trim()
+}
+
+// BonehDurfeeBound is the largest private exponent BonehDurfeeAttack can be
+// expected to find with the BD_M and BD_T lattice for key, as worked out
+// from the determinant of that lattice: its reduced vectors turn into
+// polynomials with the root over the integers once det**(1/w) is below
+// e**BD_M / sqrt(w), w being the dimension of the lattice. LLL usually does
+// a few bits better than that.
+func BonehDurfeeBound(key *RSAPublicKey) *big.Int {
+	le := float64(key.E.BitLen())
+	ly := float64(key.N.BitLen())/2 + 2
+	w := float64(len(bonehDurfeeShifts()))
+
+	// log2(det) is fixed + lx * xs, solve for lx
+	fixed, xs := 0.0, 0.0
+	for _, s := range bonehDurfeeShifts() {
+		fixed += float64(BD_M-s.k)*le + float64(s.b)*ly
+		xs += float64(s.a)
+	}
+	lx := (w*(BD_M*le-math.Log2(w)/2) - fixed) / xs
+	if lx < 1 {
+		return big.NewInt(1)
+	}
+	return new(big.Int).Lsh(Big.One, uint(lx))
+}
+
+// bdShift is one row of the BonehDurfeeAttack lattice, x**i * y**j * f**k *
+// e**(BD_M-k), whose leading monomial is x**a * y**b.
+type bdShift struct {
+	i, j, k int
+	a, b    int
+}
+
+// bonehDurfeeShifts lists the rows of the BonehDurfeeAttack lattice. Ordered by
+// the y and then x degrees of their leading monomial, they make a triangular
+// basis.
+func bonehDurfeeShifts() []bdShift {
+	var shifts []bdShift
+	for k := 0; k <= BD_M; k++ {
+		for i := 0; i <= BD_M-k; i++ {
+			shifts = append(shifts, bdShift{i, 0, k, i + k, k})
+		}
+		for j := 1; j <= BD_T; j++ {
+			shifts = append(shifts, bdShift{0, j, k, k, k + j})
+		}
+	}
+	sort.Slice(shifts, func(i, j int) bool {
+		if shifts[i].b != shifts[j].b {
+			return shifts[i].b < shifts[j].b
+		}
+		return shifts[i].a < shifts[j].a
+	})
+	return shifts
+}
+
+// BonehDurfeeAttack recovers the private exponent d of key when d is below
+// bound, which reaches past the n**0.25 where WienerAttack stops: up to
+// n**0.284 as the lattice grows, and about n**0.26 for 512 bit moduli with
+// BD_M and BD_T as they are (BonehDurfeeBound is a safe bound to give it).
+// As e*d = 1 + k*(n + 1 - (p + q)), (k, p + q) is a small root of
+// f(x, y) = 1 + x*(n + 1 - y) mod e. LLL turns multiples of f into
+// polynomials which have that root over the integers, and the resultant of
+// two of them gives p + q. It returns d and the two primes of n, or nils if
+// the attack does not work.
+func BonehDurfeeAttack(key *RSAPublicKey, bound *big.Int) (*big.Int, *big.Int, *big.Int) {
+	// k < d and p + q < 3 * sqrt(n), for primes of the same size
+	X := bound
+	Y := new(big.Int).Sqrt(key.N)
+	Y.Mul(Y, Big.Three)
+
+	// As k >= 1, d > phi / e, so there is nothing to find unless e is
+	// about as large as n
+	phi := new(big.Int).Sub(key.N, Y)
+	if phi.Quo(phi, key.E).Cmp(X) >= 0 {
+		return nil, nil, nil
+	}
+
+	f := newBiPoly(1, 1)
+	f[0][0].SetInt64(1)
+	f[1][0].Add(key.N, Big.One)
+	f[1][1].SetInt64(-1)
+
+	// fPowers[k] = f**k * e**(BD_M-k)
+	fPowers := make([]biPoly, BD_M+1)
+	power := newBiPoly(0, 0)
+	power[0][0].SetInt64(1)
+	for k := 0; k <= BD_M; k++ {
+		e := new(big.Int).Exp(key.E, big.NewInt(int64(BD_M-k)), nil)
+		fPowers[k] = power.shift(0, 0, e)
+		power = power.mul(f)
+	}
+
+	shifts := bonehDurfeeShifts()
+	column := make(map[[2]int]int)
+	for c, s := range shifts {
+		column[[2]int{s.a, s.b}] = c
+	}
+	scales := make([]*big.Int, len(shifts))
+	for c, s := range shifts {
+		scales[c] = new(big.Int).Exp(X, big.NewInt(int64(s.a)), nil)
+		scales[c].Mul(scales[c], new(big.Int).Exp(Y, big.NewInt(int64(s.b)), nil))
+	}
+
+	basis := make([][]*big.Int, len(shifts))
+	for r, s := range shifts {
+		basis[r] = make([]*big.Int, len(shifts))
+		for c := range basis[r] {
+			basis[r][c] = new(big.Int)
+		}
+		g := fPowers[s.k].shift(s.i, s.j, Big.One)
+		for a, row := range g {
+			for b, coef := range row {
+				if coef.Sign() == 0 {
+					continue
+				}
+				c := column[[2]int{a, b}]
+				basis[r][c].Mul(coef, scales[c])
+			}
+		}
+	}
+
+	var polys []biPoly
+	for _, row := range LLL(basis, nil) {
+		g := newBiPoly(BD_M, BD_M+BD_T)
+		for c, s := range shifts {
+			g[s.a][s.b].Quo(row[c], scales[c])
+		}
+		polys = append(polys, g)
+	}
+
+	// The first few reduced vectors are the likely ones to have the root
+	// over the integers, look for a pair whose resultant gives p + q
+	tries := 4
+	if tries > len(polys) {
+		tries = len(polys)
+	}
+	for i := 0; i < tries; i++ {
+		for j := i + 1; j < tries; j++ {
+			res := resultantX(polys[i], polys[j])
+			if res == nil || res.Degree() < 1 {
+				continue
+			}
+			for _, sum := range res.IntegerRoots(Big.One, Y) {
+				if d, p, q := primesFromSum(key, sum); d != nil {
+					return d, p, q
+				}
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// primesFromSum factors key.N given p + q, and returns the private exponent
+// along with the primes, or nils if sum is not p + q.
+func primesFromSum(key *RSAPublicKey, sum *big.Int) (*big.Int, *big.Int, *big.Int) {
+	// p and q are the roots of x**2 - sum*x + n
+	disc := new(big.Int).Mul(sum, sum)
+	disc.Sub(disc, new(big.Int).Lsh(key.N, 2))
+	if disc.Sign() < 0 {
+		return nil, nil, nil
+	}
+	root := new(big.Int).Sqrt(disc)
+	if new(big.Int).Mul(root, root).Cmp(disc) != 0 {
+		return nil, nil, nil
+	}
+	p := new(big.Int).Add(sum, root)
+	p.Rsh(p, 1)
+	q := new(big.Int).Sub(sum, root)
+	q.Rsh(q, 1)
+	if new(big.Int).Mul(p, q).Cmp(key.N) != 0 {
+		return nil, nil, nil
+	}
+	rsa, err := NewRSAFromPrimes(p, q, key.E)
+	if err != nil {
+		return nil, nil, nil
+	}
+	return rsa.d, p, q
+}
@@ -0,0 +1,71 @@
+package mtsn
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CBCMAC will produce the CBC-MAC of msg, which is the last block of
+// encrypting msg (padded with PadPkcs7) using AES in CBC mode.
+//
+// Both iv and key must be 16 bytes long.
+func CBCMAC(key []byte, iv []byte, msg []byte) ([]byte, error) {
+	// Copy msg first, as PadPkcs7 may write past its end
+	padded := PadPkcs7(append([]byte{}, msg...))
+	encrypted, err := EncryptAesCbc(key, iv, padded)
+	if err != nil {
+		return nil, err
+	}
+	return encrypted[len(encrypted)-16:], nil
+}
+
+// CBCMACExtend glues msg2 after msg1, given the MAC of msg1 under some key and
+// iv. The result has the same MAC as msg2 under that key and iv, and the key
+// is not needed: msg2's first block gets xor'd with mac1 and iv so that the
+// chain picks up where it would have started for msg2 alone.
+func CBCMACExtend(iv []byte, msg1 []byte, mac1 []byte, msg2 []byte) ([]byte, error) {
+	if len(msg2) < 16 {
+		return nil, errors.New("Second message must be at least one block long")
+	}
+
+	forged := PadPkcs7(append([]byte{}, msg1...))
+	forged = append(forged, XorBytes(XorBytes(msg2[:16], iv), mac1)...)
+	forged = append(forged, msg2[16:]...)
+	return forged, nil
+}
+
+// CBCMACForgeIV swaps the first block of msg for firstBlock when the
+// attacker gets to pick the IV sent along with the message. It returns the
+// new message and the IV which keeps the MAC of msg valid for it.
+func CBCMACForgeIV(iv []byte, msg []byte, firstBlock []byte) ([]byte, []byte, error) {
+	if len(msg) < 16 || len(firstBlock) != 16 {
+		return nil, nil, fmt.Errorf("Need a message of at least 16 bytes and a 16 byte block, not %d and %d",
+			len(msg), len(firstBlock))
+	}
+
+	forged := append(append([]byte{}, firstBlock...), msg[16:]...)
+	newIV := XorBytes(XorBytes(iv, msg[:16]), firstBlock)
+	return forged, newIV, nil
+}
+
+// CBCMACSecondPreimage uses CBC-MAC with a known key as a hash, and finds a
+// message starting with prefix (padded with PadPkcs7) which hashes to the
+// same thing as target. A glue block after the prefix sends the chain back
+// to where target's first block leaves it, and the rest of target follows.
+func CBCMACSecondPreimage(key []byte, iv []byte, target []byte, prefix []byte) ([]byte, error) {
+	if len(target) < 16 {
+		return nil, errors.New("Target must be at least one block long")
+	}
+
+	// The chain after the padded prefix is its last encrypted block
+	padded := PadPkcs7(append([]byte{}, prefix...))
+	encrypted, err := EncryptAesCbc(key, iv, padded)
+	if err != nil {
+		return nil, err
+	}
+	state := encrypted[len(encrypted)-16:]
+
+	forged := append(padded, XorBytes(XorBytes(target[:16], iv), state)...)
+	forged = append(forged, target[16:]...)
+	return forged, nil
+}
@@ -0,0 +1,131 @@
+package mtsn
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+)
+
+// Characters a session id is usually made of
+const BASE64_ALPHABET = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+
+// Characters used to shift the request around block boundaries, which
+// should not compress with anything
+const crimeJunk = "!@#$%^&*()-_[]{}|;:<>,.?~`'\"\\ \t"
+
+// CompressionOracle formats a request carrying a secret session cookie along
+// with a body of the attacker's choosing, compresses it and encrypts it,
+// and only lets out the length of the result. Every call uses a fresh key
+// and nonce (or IV). It is not safe to use from several goroutines.
+type CompressionOracle struct {
+	Secret []byte
+
+	// CBC picks AES in CBC mode instead of CTR mode
+	CBC bool
+
+	// Setting up a flate.Writer is expensive, so keep one around
+	compressor *flate.Writer
+}
+
+// Request builds the plain request sent for body.
+func (o *CompressionOracle) Request(body []byte) []byte {
+	return []byte(fmt.Sprintf("POST / HTTP/1.1\nHost: hapless.com\nCookie: sessionid=%s\nContent-Length: %d\n%s",
+		o.Secret, len(body), body))
+}
+
+// Length returns how long the compressed and encrypted request for body is.
+func (o *CompressionOracle) Length(body []byte) int {
+	var compressed bytes.Buffer
+	if o.compressor == nil {
+		writer, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+		if err != nil {
+			panic(err)
+		}
+		o.compressor = writer
+	} else {
+		o.compressor.Reset(&compressed)
+	}
+	o.compressor.Write(o.Request(body))
+	o.compressor.Close()
+
+	key := GenerateRandomKey()
+	if !o.CBC {
+		nonce := GenerateRandomKey()[:8]
+		return len(CtrCoding(nonce, key, compressed.Bytes()))
+	}
+
+	iv := GenerateRandomKey()
+	encrypted, err := EncryptAesCbc(key, iv, PadPkcs7(compressed.Bytes()))
+	if err != nil {
+		panic(err)
+	}
+	return len(encrypted)
+}
+
+// CompressionAttack recovers the secret following known in requests sent
+// through oracle, one character at a time out of alphabet, until it has
+// secretLen of them.
+//
+// A body repeating the secret compresses better than one which does not, but
+// with a block cipher the difference gets lost unless the request sits
+// right on a block boundary. So every guess is scored over bodies shifted by
+// 0 to blockSize - 1 bytes of junk, which finds the boundary whatever the
+// cipher is. Guesses still tied are told apart by trying the character
+// after them as well. For a stream cipher, a blockSize of 1 is enough.
+func CompressionAttack(oracle func([]byte) int, known []byte, alphabet string, secretLen int, blockSize int) ([]byte, error) {
+	var junk []byte
+	for _, c := range []byte(crimeJunk) {
+		if bytes.IndexByte([]byte(alphabet), c) == -1 && bytes.IndexByte(known, c) == -1 {
+			junk = append(junk, c)
+		}
+	}
+	if len(junk) < blockSize {
+		return nil, fmt.Errorf("Only %d junk characters left to line up blocks of %d", len(junk), blockSize)
+	}
+
+	score := func(guess []byte) int {
+		total := 0
+		for shift := 0; shift < blockSize; shift++ {
+			body := append(append([]byte{}, junk[:shift]...), guess...)
+			total += oracle(body)
+		}
+		return total
+	}
+
+	secret := []byte{}
+	for len(secret) < secretLen {
+		guess := append(append([]byte{}, known...), secret...)
+
+		best := []byte{}
+		bestScore := 0
+		for _, c := range []byte(alphabet) {
+			s := score(append(guess, c))
+			if len(best) == 0 || s < bestScore {
+				best, bestScore = []byte{c}, s
+			} else if s == bestScore {
+				best = append(best, c)
+			}
+		}
+
+		if len(best) > 1 && len(secret)+1 < secretLen {
+			best = crimeTieBreak(score, guess, best, alphabet)
+		}
+		secret = append(secret, best[0])
+	}
+	return secret, nil
+}
+
+// crimeTieBreak picks which of tied is best by looking one character further.
+func crimeTieBreak(score func([]byte) int, guess []byte, tied []byte, alphabet string) []byte {
+	var best []byte
+	bestScore := 0
+	for _, c := range tied {
+		for _, next := range []byte(alphabet) {
+			s := score(append(append(append([]byte{}, guess...), c), next))
+			if best == nil || s < bestScore {
+				best, bestScore = []byte{c}, s
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,50 @@
+package mtsn
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// CRT combines the congruences x = residues[i] mod moduli[i] into a single
+// x mod m, using the Chinese Remainder Theorem, and returns both x and m. The
+// moduli do not need to be coprime, but an error is returned if the
+// congruences contradict each other.
+func CRT(residues []*big.Int, moduli []*big.Int) (*big.Int, *big.Int, error) {
+	if len(residues) != len(moduli) {
+		return nil, nil, fmt.Errorf("Got %d residues for %d moduli", len(residues), len(moduli))
+	}
+
+	x := big.NewInt(0)
+	m := big.NewInt(1)
+
+	for i, mod := range moduli {
+		if mod.Sign() <= 0 {
+			return nil, nil, fmt.Errorf("Modulus %d (%v) is not positive", i, mod)
+		}
+		r := new(big.Int).Mod(residues[i], mod)
+
+		// Solve x + m*t = r mod mod, i.e. m*t = r - x mod mod
+		g := new(big.Int).GCD(nil, nil, m, mod)
+		diff := new(big.Int).Sub(r, x)
+		if new(big.Int).Mod(diff, g).Sign() != 0 {
+			return nil, nil, fmt.Errorf("Congruence %d (%v mod %v) contradicts the previous ones", i, r, mod)
+		}
+
+		modG := new(big.Int).Div(mod, g)
+		mG := new(big.Int).Mod(new(big.Int).Div(m, g), modG)
+		t := new(big.Int).Div(diff, g)
+		if modG.Cmp(Big.One) != 0 {
+			inv, err := InvMod(mG, modG)
+			if err != nil {
+				return nil, nil, err
+			}
+			t.Mul(t, inv)
+		}
+		t.Mod(t, modG)
+
+		x.Add(x, t.Mul(t, m))
+		m.Mul(m, modG)
+		x.Mod(x, m)
+	}
+	return x, m, nil
+}
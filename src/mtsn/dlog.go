@@ -0,0 +1,334 @@
+package mtsn
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// How many times Kangaroo and PollardRho start over with a new walk before
+// giving up
+const DLOG_ATTEMPTS = 8
+
+// Number of multipliers in the walk of PollardRho
+const rhoBranches = 20
+
+// SmallFactors lists the distinct primes below bound which divide n, by trial
+// division.
+func SmallFactors(n *big.Int, bound int64) []*big.Int {
+	var factors []*big.Int
+	rest := new(big.Int).Set(n)
+	mod := new(big.Int)
+	for r := int64(2); r < bound && rest.Cmp(Big.One) > 0; r++ {
+		factor := big.NewInt(r)
+		if mod.Mod(rest, factor).Sign() != 0 {
+			continue
+		}
+		factors = append(factors, factor)
+		for mod.Mod(rest, factor).Sign() == 0 {
+			rest.Div(rest, factor)
+		}
+	}
+	return factors
+}
+
+// dlogBudget counts the steps taken by a discrete log solver, and stops it
+// when the budget is spent or its context is done.
+type dlogBudget struct {
+	ctx   context.Context
+	limit int64
+	steps int64
+}
+
+func (d *dlogBudget) step() error {
+	d.steps++
+	if d.limit > 0 && d.steps > d.limit {
+		return fmt.Errorf("Gave up after %d steps", d.limit)
+	}
+	// Checking the context is slow compared to a step
+	if d.steps&1023 == 0 {
+		return d.ctx.Err()
+	}
+	return nil
+}
+
+// lowWord picks a walk branch out of x, from its lowest bits.
+func lowWord(x *big.Int, salt uint64) uint64 {
+	words := x.Bits()
+	if len(words) == 0 {
+		return salt
+	}
+	return uint64(words[0]) + salt
+}
+
+// DlogGroup is a cyclic group, written multiplicatively, as KangarooIn sees
+// it.
+type DlogGroup[E any] interface {
+	// Exp is g^k
+	Exp(g E, k *big.Int) E
+
+	// Mul is x * y, and is free to reuse x for the result
+	Mul(x, y E) E
+
+	Equal(x, y E) bool
+
+	// Label depends on x only, and is spread out enough to pick a jump with
+	Label(x E) uint64
+}
+
+// modPGroup is the multiplicative group of the integers mod p.
+type modPGroup struct {
+	p *big.Int
+
+	// Scratch space for Mul, allocating is slower than the maths
+	product, quotient *big.Int
+}
+
+func newModPGroup(p *big.Int) *modPGroup {
+	return &modPGroup{p, new(big.Int), new(big.Int)}
+}
+
+func (m *modPGroup) Exp(g *big.Int, k *big.Int) *big.Int {
+	return new(big.Int).Exp(g, k, m.p)
+}
+
+func (m *modPGroup) Mul(x, y *big.Int) *big.Int {
+	m.product.Mul(x, y)
+	m.quotient.QuoRem(m.product, m.p, x)
+	return x
+}
+
+func (m *modPGroup) Equal(x, y *big.Int) bool {
+	return x.Cmp(y) == 0
+}
+
+func (m *modPGroup) Label(x *big.Int) uint64 {
+	return lowWord(x, 0)
+}
+
+// Kangaroo will find x between a and b (both included) such that
+// g^x = y mod p, with Pollard's lambda method. It takes about
+// 4 * sqrt(b - a) steps, each one multiplication mod p. A budget of 0 steps
+// means no limit, otherwise an error is returned once budget steps have been
+// taken, or as soon as ctx is done.
+func Kangaroo(ctx context.Context, g, y, p, a, b *big.Int, budget int64) (*big.Int, error) {
+	return KangarooIn[*big.Int](ctx, newModPGroup(p), g, new(big.Int).Mod(y, p), a, b, budget)
+}
+
+// KangarooIn is Kangaroo in any group, finding x between a and b such that
+// g^x = y.
+//
+// A tame kangaroo hops from g^b and lays a trap where it stops. A wild one
+// hops from y with the same jumps, which depend only on where it stands, so
+// if it ever lands where the tame one did it follows it into the trap. The
+// walk can miss, in which case it is tried again with other jumps.
+func KangarooIn[E any](ctx context.Context, group DlogGroup[E], g, y E, a, b *big.Int, budget int64) (*big.Int, error) {
+	width := new(big.Int).Sub(b, a)
+	if width.Sign() < 0 {
+		return nil, fmt.Errorf("Interval [%v, %v] is empty", a, b)
+	}
+
+	// Jumps are the powers of 2 below 2**k, whose mean should be about half
+	// the square root of the width.
+	target := new(big.Int).Sqrt(width)
+	target.Rsh(target, 1)
+	if target.BitLen() > 56 {
+		return nil, fmt.Errorf("Interval [%v, %v] is too wide", a, b)
+	}
+	k := uint64(1)
+	for ((1<<k)-1)/int64(k) < target.Int64() {
+		k++
+	}
+	mean := ((1 << k) - 1) / int64(k)
+
+	jumps := make([]*big.Int, k)
+	powers := make([]E, k)
+	for i := range jumps {
+		jumps[i] = new(big.Int).Lsh(Big.One, uint(i))
+		powers[i] = group.Exp(g, jumps[i])
+	}
+
+	counter := &dlogBudget{ctx: ctx, limit: budget}
+	for attempt := uint64(0); attempt < DLOG_ATTEMPTS; attempt++ {
+		hop := func(pos E, dist *big.Int) E {
+			i := (group.Label(pos) + attempt) % k
+			dist.Add(dist, jumps[i])
+			return group.Mul(pos, powers[i])
+		}
+
+		tame := group.Exp(g, b)
+		tameDist := new(big.Int)
+		for n := int64(0); n < 4*mean; n++ {
+			if err := counter.step(); err != nil {
+				return nil, err
+			}
+			tame = hop(tame, tameDist)
+		}
+
+		// Past this distance, the wild kangaroo has gone by the trap. It
+		// starts on a copy of y, as Mul can write over its first argument.
+		limit := new(big.Int).Add(width, tameDist)
+		wild := group.Mul(group.Exp(g, Big.Zero), y)
+		wildDist := new(big.Int)
+		for wildDist.Cmp(limit) <= 0 {
+			if group.Equal(wild, tame) {
+				x := new(big.Int).Add(b, tameDist)
+				return x.Sub(x, wildDist), nil
+			}
+			if err := counter.step(); err != nil {
+				return nil, err
+			}
+			wild = hop(wild, wildDist)
+		}
+	}
+	return nil, fmt.Errorf("Could not find the log in [%v, %v]", a, b)
+}
+
+// PollardRho will find x below order such that g^x = y mod p, where order is
+// the order of g, with Pollard's rho method. It takes about sqrt(order)
+// steps, each one about three multiplications mod p, and works best when
+// order is prime. budget and ctx work like they do for Kangaroo.
+//
+// The walk multiplies by one of a few random g^a * y^b at every step,
+// picked by where it stands, so it ends up going round in a loop. Finding
+// the loop gives two ways of writing the same element, and so an equation
+// for x.
+func PollardRho(ctx context.Context, g, y, p, order *big.Int, budget int64) (*big.Int, error) {
+	if order.Cmp(Big.One) <= 0 {
+		return nil, fmt.Errorf("Order %v is too small", order)
+	}
+
+	counter := &dlogBudget{ctx: ctx, limit: budget}
+	for attempt := 0; attempt < DLOG_ATTEMPTS; attempt++ {
+		walk, err := newRhoWalk(g, y, p, order)
+		if err != nil {
+			return nil, err
+		}
+
+		slow, err := walk.start()
+		if err != nil {
+			return nil, err
+		}
+		fast := slow.copy()
+
+		for {
+			if err := counter.step(); err != nil {
+				return nil, err
+			}
+			walk.next(slow)
+			walk.next(fast)
+			walk.next(fast)
+			if slow.x.Cmp(fast.x) == 0 {
+				break
+			}
+		}
+
+		// g^a1 y^b1 = g^a2 y^b2, so (b2 - b1) x = a1 - a2 mod order
+		lhs := new(big.Int).Sub(fast.b, slow.b)
+		rhs := new(big.Int).Sub(slow.a, fast.a)
+		if x := solveDlogCongruence(g, y, p, order, lhs, rhs); x != nil {
+			return x, nil
+		}
+	}
+	return nil, fmt.Errorf("Could not find the log after %d walks", DLOG_ATTEMPTS)
+}
+
+// rhoPoint is a spot on the walk of PollardRho, x = g^a * y^b mod p.
+type rhoPoint struct {
+	x, a, b *big.Int
+}
+
+func (r *rhoPoint) copy() *rhoPoint {
+	return &rhoPoint{new(big.Int).Set(r.x), new(big.Int).Set(r.a), new(big.Int).Set(r.b)}
+}
+
+// rhoWalk is an r-adding walk: each step multiplies by one of the
+// multipliers g^a[i] * y^b[i].
+type rhoWalk struct {
+	g, y, p, order *big.Int
+	multipliers    []*rhoPoint
+
+	// Scratch space for next
+	product, quotient *big.Int
+}
+
+func newRhoWalk(g, y, p, order *big.Int) (*rhoWalk, error) {
+	w := &rhoWalk{g: g, y: y, p: p, order: order, product: new(big.Int), quotient: new(big.Int)}
+	for i := 0; i < rhoBranches; i++ {
+		m, err := w.start()
+		if err != nil {
+			return nil, err
+		}
+		w.multipliers = append(w.multipliers, m)
+	}
+	return w, nil
+}
+
+// start picks a random point to walk from.
+func (w *rhoWalk) start() (*rhoPoint, error) {
+	a, err := rand.Int(rand.Reader, w.order)
+	if err != nil {
+		return nil, err
+	}
+	b, err := rand.Int(rand.Reader, w.order)
+	if err != nil {
+		return nil, err
+	}
+	x := new(big.Int).Exp(w.g, a, w.p)
+	x.Mul(x, new(big.Int).Exp(w.y, b, w.p))
+	x.Mod(x, w.p)
+	return &rhoPoint{x, a, b}, nil
+}
+
+func (w *rhoWalk) next(r *rhoPoint) {
+	m := w.multipliers[lowWord(r.x, 0)%rhoBranches]
+	w.product.Mul(r.x, m.x)
+	w.quotient.QuoRem(w.product, w.p, r.x)
+	r.a.Add(r.a, m.a)
+	if r.a.Cmp(w.order) >= 0 {
+		r.a.Sub(r.a, w.order)
+	}
+	r.b.Add(r.b, m.b)
+	if r.b.Cmp(w.order) >= 0 {
+		r.b.Sub(r.b, w.order)
+	}
+}
+
+// solveDlogCongruence finds x with lhs * x = rhs mod order and g^x = y mod p.
+// When lhs shares a factor d with order there are d candidates, which get
+// tried in turn as long as there are not too many. It returns nil if none
+// of them fit.
+func solveDlogCongruence(g, y, p, order, lhs, rhs *big.Int) *big.Int {
+	lhs = new(big.Int).Mod(lhs, order)
+	rhs = new(big.Int).Mod(rhs, order)
+	if lhs.Sign() == 0 {
+		return nil
+	}
+
+	d := new(big.Int).GCD(nil, nil, lhs, order)
+	if new(big.Int).Mod(rhs, d).Sign() != 0 || d.BitLen() > 16 {
+		return nil
+	}
+	reduced := new(big.Int).Div(order, d)
+	x := new(big.Int).Div(rhs, d)
+	if reduced.Cmp(Big.One) != 0 {
+		inv, err := InvMod(new(big.Int).Div(lhs, d), reduced)
+		if err != nil {
+			return nil
+		}
+		x.Mul(x, inv)
+		x.Mod(x, reduced)
+	} else {
+		x.SetInt64(0)
+	}
+
+	want := new(big.Int).Mod(y, p)
+	for t := int64(0); t < d.Int64(); t++ {
+		if new(big.Int).Exp(g, x, p).Cmp(want) == 0 {
+			return x
+		}
+		x.Add(x, reduced)
+	}
+	return nil
+}
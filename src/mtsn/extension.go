@@ -0,0 +1,103 @@
+package mtsn
+
+import (
+	"fmt"
+	"hashhacks"
+	"md4hacks"
+	"md5hacks"
+	"sha1hacks"
+	"sha256hacks"
+	"sha512hacks"
+)
+
+// ExtendableHash describes a Merkle–Damgård hash well enough to run a length
+// extension attack against it.
+type ExtendableHash struct {
+	Name      string
+	Size      int
+	BlockSize int
+
+	// New starts a fresh hash, and Resume picks one up from a digest
+	New    func() hashhacks.HashState
+	Resume hashhacks.Resumer
+}
+
+// Padding returns the bytes the hash appends to a message of length bytes
+// before its last compression.
+func (h *ExtendableHash) Padding(length uint64) []byte {
+	return h.New().Padding(length)
+}
+
+// Extend hashes data as if it came straight after a message of length bytes
+// (padding included) which hashed to digest.
+func (h *ExtendableHash) Extend(digest []byte, length uint64, data []byte) ([]byte, error) {
+	if len(digest) != h.Size {
+		return nil, fmt.Errorf("%s digest must be %d bytes, got %d", h.Name, h.Size, len(digest))
+	}
+	if length%uint64(h.BlockSize) != 0 {
+		return nil, fmt.Errorf("%s can only resume on a %d byte boundary, got %d", h.Name, h.BlockSize, length)
+	}
+	state := h.Resume(digest, length)
+	state.Write(data)
+	return state.Sum(nil), nil
+}
+
+// The hashes LengthExtension knows about.
+var (
+	ExtendSHA1 = &ExtendableHash{
+		Name: "SHA-1", Size: sha1hacks.Size, BlockSize: sha1hacks.BlockSize,
+		New: sha1hacks.NewState, Resume: sha1hacks.HackedHasher,
+	}
+	ExtendMD4 = &ExtendableHash{
+		Name: "MD4", Size: md4hacks.Size, BlockSize: md4hacks.BlockSize,
+		New: md4hacks.NewState, Resume: md4hacks.HackedHasher,
+	}
+	ExtendMD5 = &ExtendableHash{
+		Name: "MD5", Size: md5hacks.Size, BlockSize: md5hacks.BlockSize,
+		New: md5hacks.NewState, Resume: md5hacks.HackedHasher,
+	}
+	ExtendSHA256 = &ExtendableHash{
+		Name: "SHA-256", Size: sha256hacks.Size, BlockSize: sha256hacks.BlockSize,
+		New: sha256hacks.NewState, Resume: sha256hacks.HackedHasher,
+	}
+	ExtendSHA512 = &ExtendableHash{
+		Name: "SHA-512", Size: sha512hacks.Size, BlockSize: sha512hacks.BlockSize,
+		New: sha512hacks.NewState, Resume: sha512hacks.HackedHasher,
+	}
+)
+
+// LengthExtension forges a MAC for msg + padding + suffix, given digest the
+// MAC of msg computed as H(key + msg), where the key is keyLen bytes long.
+// It returns the forged message (without the key) and its digest.
+func LengthExtension(h *ExtendableHash, digest []byte, msg []byte, keyLen int, suffix []byte) ([]byte, []byte, error) {
+	padding := h.Padding(uint64(keyLen + len(msg)))
+	processed := uint64(keyLen + len(msg) + len(padding))
+
+	newDigest, err := h.Extend(digest, processed, suffix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newMsg := make([]byte, 0, len(msg)+len(padding)+len(suffix))
+	newMsg = append(newMsg, msg...)
+	newMsg = append(newMsg, padding...)
+	newMsg = append(newMsg, suffix...)
+	return newMsg, newDigest, nil
+}
+
+// LengthExtensionSearch runs LengthExtension for every key length from 0 up
+// to maxKeyLen, until verify accepts the forgery. It returns the key length
+// that worked along with the forged message and digest.
+func LengthExtensionSearch(h *ExtendableHash, digest []byte, msg []byte, suffix []byte, maxKeyLen int,
+	verify func(msg []byte, digest []byte) bool) (int, []byte, []byte, error) {
+	for keyLen := 0; keyLen <= maxKeyLen; keyLen++ {
+		newMsg, newDigest, err := LengthExtension(h, digest, msg, keyLen, suffix)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		if verify(newMsg, newDigest) {
+			return keyLen, newMsg, newDigest, nil
+		}
+	}
+	return 0, nil, nil, fmt.Errorf("No key length up to %d fooled the verifier", maxKeyLen)
+}
@@ -0,0 +1,172 @@
+package mtsn
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// LinearPad describes a message padded as A*m + B before being encrypted.
+type LinearPad struct {
+	A *big.Int
+	B *big.Int
+}
+
+// checkBroadcast makes sure all the keys share the same exponent, and
+// returns it.
+func checkBroadcast(keys []*RSAPublicKey, ciphertexts []*big.Int) (int, error) {
+	if len(keys) == 0 || len(keys) != len(ciphertexts) {
+		return 0, fmt.Errorf("Got %d keys for %d ciphertexts", len(keys), len(ciphertexts))
+	}
+	e := keys[0].E
+	for _, key := range keys {
+		if key.E.Cmp(e) != 0 {
+			return 0, fmt.Errorf("Keys use different exponents (%v and %v)", e, key.E)
+		}
+	}
+	if !e.IsInt64() || e.Int64() > 1<<16 {
+		return 0, fmt.Errorf("Exponent %v too big for a broadcast attack", e)
+	}
+	return int(e.Int64()), nil
+}
+
+// HastadBroadcast recovers a message m sent, unpadded, to several keys that
+// share the same (small) exponent e. The ciphertexts are combined with CRT
+// into m**e mod n1*n2*..., which is m**e itself when there are at least e
+// keys.
+func HastadBroadcast(keys []*RSAPublicKey, ciphertexts []*big.Int) (*big.Int, error) {
+	e, err := checkBroadcast(keys, ciphertexts)
+	if err != nil {
+		return nil, err
+	}
+
+	moduli := make([]*big.Int, len(keys))
+	for i, key := range keys {
+		moduli[i] = key.N
+	}
+	combined, _, err := CRT(ciphertexts, moduli)
+	if err != nil {
+		return nil, err
+	}
+
+	m, rem := RootRem(combined, e)
+	if rem.Sign() != 0 {
+		return nil, fmt.Errorf("Combined ciphertext is not an exact %d-th power, need more keys", e)
+	}
+	return m, nil
+}
+
+// HastadPadded recovers a message m sent to several keys sharing the same
+// exponent e, where the i-th copy was padded as pads[i].A * m + pads[i].B
+// before encryption. The ciphertexts give a monic polynomial of degree e,
+// mod the product N of all the moduli, with m as a root, which is found
+// with Coppersmith's method as long as m is below bound (CoppersmithBound(N, e)
+// if bound is nil).
+func HastadPadded(keys []*RSAPublicKey, ciphertexts []*big.Int, pads []*LinearPad, bound *big.Int) (*big.Int, error) {
+	e, err := checkBroadcast(keys, ciphertexts)
+	if err != nil {
+		return nil, err
+	}
+	if len(pads) != len(keys) {
+		return nil, fmt.Errorf("Got %d pads for %d keys", len(pads), len(keys))
+	}
+
+	// g_i(x) = a_i**-e ((a_i*x + b_i)**e - c_i) mod n_i, which is monic
+	polys := make([]Poly, len(keys))
+	moduli := make([]*big.Int, len(keys))
+	for i, key := range keys {
+		n := key.N
+		g := Poly{pads[i].B, pads[i].A}.Pow(e, n)
+		g = g.Add(Poly{new(big.Int).Neg(ciphertexts[i])})
+
+		aInvE, err := InvMod(new(big.Int).Exp(pads[i].A, big.NewInt(int64(e)), n), n)
+		if err != nil {
+			return nil, err
+		}
+		polys[i] = g.Scale(aInvE).Mod(n)
+		moduli[i] = n
+	}
+
+	// Stitch the coefficients together with CRT
+	combined := make(Poly, e+1)
+	var N *big.Int
+	for j := 0; j <= e; j++ {
+		residues := make([]*big.Int, len(polys))
+		for i, g := range polys {
+			residues[i] = Big.Zero
+			if j < len(g) {
+				residues[i] = g[j]
+			}
+		}
+		combined[j], N, err = CRT(residues, moduli)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if bound == nil {
+		bound = CoppersmithBound(N, e)
+	}
+	m := Coppersmith(combined, N, bound)
+	if m == nil {
+		return nil, fmt.Errorf("Could not find a small root, need more keys")
+	}
+	return m, nil
+}
+
+// CoppersmithBound is the largest bound Coppersmith is sure to work with for
+// a polynomial of degree d mod N. With the d+1 rows of its lattice, LLL
+// gives a vector of length at most 2**(d/4) * det**(1/(d+1)), where
+// det = N**d * X**(d*(d+1)/2), and that vector only turns into a polynomial
+// with x0 as a root over the integers if it is shorter than N/sqrt(d+1).
+// This holds for X < N**(2/(d*(d+1))) / (sqrt(2) * (d+1)**(1/d)), and the
+// divisor is below 3 for any d.
+func CoppersmithBound(N *big.Int, d int) *big.Int {
+	bound := Root(N, d*(d+1)/2)
+	return bound.Div(bound, Big.Three)
+}
+
+// Coppersmith looks for a root x0 of the monic polynomial f mod N, with
+// 0 <= x0 < bound, using the basic Howgrave-Graham lattice: LLL finds a
+// combination of f(x) and N*x**i with small coefficients, which then has x0
+// as a root over the integers. It returns nil if no such root is found.
+func Coppersmith(f Poly, N *big.Int, bound *big.Int) *big.Int {
+	d := f.Degree()
+	if d < 1 {
+		return nil
+	}
+
+	// Powers of the bound X**i
+	powers := make([]*big.Int, d+1)
+	powers[0] = big.NewInt(1)
+	for i := 1; i <= d; i++ {
+		powers[i] = new(big.Int).Mul(powers[i-1], bound)
+	}
+
+	// Rows are the coefficients of N*(xX)**i and f(xX)
+	basis := make([][]*big.Int, d+1)
+	for i := 0; i <= d; i++ {
+		basis[i] = make([]*big.Int, d+1)
+		for j := range basis[i] {
+			basis[i][j] = new(big.Int)
+		}
+	}
+	for i := 0; i < d; i++ {
+		basis[i][i].Mul(N, powers[i])
+	}
+	for j := 0; j <= d; j++ {
+		basis[d][j].Mul(f[j], powers[j])
+	}
+
+	for _, row := range LLL(basis, nil) {
+		g := make(Poly, d+1)
+		for j := range row {
+			g[j] = new(big.Int).Quo(row[j], powers[j])
+		}
+		for _, root := range g.IntegerRoots(Big.Zero, bound) {
+			if new(big.Int).Mod(f.Eval(root), N).Sign() == 0 {
+				return root
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package mtsn
+
+import (
+	"crypto/subtle"
+	"hash"
+)
+
+// HMAC computes an HMAC (RFC 2104) over any hash. It implements hash.Hash,
+// so the text can be written to it in several pieces.
+//
+// Example usage:
+//
+//    mac := NewHMAC(sha256.New, key)
+//    mac.Write(text)
+//    digest := mac.Sum(nil)
+//
+//    // Later on
+//    mac.Reset()
+//    mac.Write(text)
+//    ok := mac.Verify(digest)
+type HMAC struct {
+	inner hash.Hash
+	outer hash.Hash
+	ipad  []byte
+	opad  []byte
+}
+
+// NewHMAC sets up an HMAC keyed with key, using the hash built by newHash.
+func NewHMAC(newHash func() hash.Hash, key []byte) *HMAC {
+	h := &HMAC{inner: newHash(), outer: newHash()}
+	blockSize := h.inner.BlockSize()
+
+	// Keys longer than a block get hashed first, and every key is then
+	// padded with zeros up to the block size.
+	if len(key) > blockSize {
+		h.outer.Write(key)
+		key = h.outer.Sum(nil)
+		h.outer.Reset()
+	}
+	h.ipad = make([]byte, blockSize)
+	h.opad = make([]byte, blockSize)
+	copy(h.ipad, key)
+	copy(h.opad, key)
+	for i := 0; i < blockSize; i++ {
+		h.ipad[i] ^= 0x36
+		h.opad[i] ^= 0x5c
+	}
+
+	h.inner.Write(h.ipad)
+	return h
+}
+
+func (h *HMAC) Size() int { return h.outer.Size() }
+
+func (h *HMAC) BlockSize() int { return h.inner.BlockSize() }
+
+func (h *HMAC) Write(p []byte) (int, error) {
+	return h.inner.Write(p)
+}
+
+// Sum appends the HMAC of everything written so far to in. More text can
+// still be written afterwards.
+func (h *HMAC) Sum(in []byte) []byte {
+	innerDigest := h.inner.Sum(nil)
+
+	h.outer.Reset()
+	h.outer.Write(h.opad)
+	h.outer.Write(innerDigest)
+	return h.outer.Sum(in)
+}
+
+// Reset forgets all the text written, but keeps the key.
+func (h *HMAC) Reset() {
+	h.inner.Reset()
+	h.inner.Write(h.ipad)
+}
+
+// Verify will check, in constant time, whether mac is the HMAC of everything
+// written so far.
+func (h *HMAC) Verify(mac []byte) bool {
+	return subtle.ConstantTimeCompare(h.Sum(nil), mac) == 1
+}
+
+// HMACSum will produce the HMAC digest of text, given a key and a hash.
+func HMACSum(newHash func() hash.Hash, key []byte, text []byte) []byte {
+	h := NewHMAC(newHash, key)
+	h.Write(text)
+	return h.Sum(nil)
+}
@@ -0,0 +1,159 @@
+package mtsn
+
+import (
+	"math/big"
+)
+
+// LLL_DELTA is the usual Lovász constant of 3/4 for LLL.
+var LLL_DELTA = big.NewRat(3, 4)
+
+// LLL_ETA is how far above 1/2 LLL lets the Gram-Schmidt coefficients go
+// before size reducing, as they are only known to the precision of a
+// big.Float.
+var LLL_ETA = big.NewFloat(0.51)
+
+// LLL reduces the lattice spanned by the rows of basis using the
+// Lenstra–Lenstra–Lovász algorithm with Lovász constant delta (LLL_DELTA if
+// nil), and returns the reduced basis. The rows must be linearly
+// independent. The input is left untouched.
+//
+// The basis and its Gram matrix are kept exact, while the Gram-Schmidt
+// coefficients are computed from the Gram matrix as floats, with a few
+// bits of precision per row (Nguyen and Stehlé's L2). Exact fractions are
+// just as correct, but their size makes them hopeless past a dozen rows
+// of large numbers.
+func LLL(basis [][]*big.Int, delta *big.Rat) [][]*big.Int {
+	if delta == nil {
+		delta = LLL_DELTA
+	}
+	n := len(basis)
+
+	b := make([][]*big.Int, n)
+	for i, row := range basis {
+		b[i] = make([]*big.Int, len(row))
+		for j, v := range row {
+			b[i][j] = new(big.Int).Set(v)
+		}
+	}
+	if n < 2 {
+		return b
+	}
+
+	prec := uint(2*n + 128)
+	newFloat := func() *big.Float { return new(big.Float).SetPrec(prec) }
+	deltaFloat := newFloat().SetRat(delta)
+
+	gram := make([][]*big.Int, n)
+	for i := range gram {
+		gram[i] = make([]*big.Int, n)
+		for j := range gram[i] {
+			gram[i][j] = dotInt(b[i], b[j])
+		}
+	}
+
+	// r[i][j] = <b[i], bStar[j]> and mu[i][j] = r[i][j] / r[j][j]
+	r := make([][]*big.Float, n)
+	mu := make([][]*big.Float, n)
+	for i := range r {
+		r[i] = make([]*big.Float, n)
+		mu[i] = make([]*big.Float, n)
+		for j := range r[i] {
+			r[i][j] = newFloat()
+			mu[i][j] = newFloat()
+		}
+	}
+	tmp := newFloat()
+	orthogonalise := func(k int) {
+		for j := 0; j <= k; j++ {
+			r[k][j].SetInt(gram[k][j])
+			for i := 0; i < j; i++ {
+				r[k][j].Sub(r[k][j], tmp.Mul(mu[j][i], r[k][i]))
+			}
+			if j < k {
+				mu[k][j].Quo(r[k][j], r[j][j])
+			}
+		}
+	}
+
+	// b[k] -= x * b[j], keeping the Gram matrix in step
+	x := new(big.Int)
+	t := new(big.Int)
+	subtract := func(k, j int) {
+		for i := range b[k] {
+			b[k][i].Sub(b[k][i], t.Mul(x, b[j][i]))
+		}
+		// <b[k] - x b[j], b[k] - x b[j]> = G[k][k] - 2x G[k][j] + x**2 G[j][j]
+		kk := new(big.Int).Mul(x, gram[k][j])
+		kk.Lsh(kk, 1)
+		kk.Sub(gram[k][k], kk)
+		kk.Add(kk, t.Mul(t.Mul(x, x), gram[j][j]))
+		for i := 0; i < n; i++ {
+			if i != k {
+				gram[k][i].Sub(gram[k][i], t.Mul(x, gram[j][i]))
+				gram[i][k] = gram[k][i]
+			}
+		}
+		gram[k][k] = kk
+	}
+
+	eta := newFloat().Set(LLL_ETA)
+	rounded := newFloat()
+	k := 1
+	orthogonalise(0)
+	for k < n {
+		// Size reduce b[k], again as long as the floats found something
+		for reduced := true; reduced; {
+			orthogonalise(k)
+			reduced = false
+			for j := k - 1; j >= 0; j-- {
+				if tmp.Abs(mu[k][j]).Cmp(eta) <= 0 {
+					continue
+				}
+				reduced = true
+				rounded.Add(mu[k][j], big.NewFloat(0.5).SetPrec(prec))
+				rounded.Int(x)
+				if rounded.Sign() < 0 && !rounded.IsInt() {
+					x.Sub(x, Big.One)
+				}
+				subtract(k, j)
+				xFloat := newFloat().SetInt(x)
+				for i := 0; i < j; i++ {
+					mu[k][i].Sub(mu[k][i], tmp.Mul(xFloat, mu[j][i]))
+				}
+				mu[k][j].Sub(mu[k][j], xFloat)
+			}
+		}
+
+		// Lovász condition, B[k] >= (delta - mu[k][k-1]**2) * B[k-1]
+		bound := newFloat().Mul(mu[k][k-1], mu[k][k-1])
+		bound.Sub(deltaFloat, bound)
+		bound.Mul(bound, r[k-1][k-1])
+		if r[k][k].Cmp(bound) >= 0 {
+			k++
+			continue
+		}
+
+		// Swap b[k] and b[k-1], and the Gram matrix to match
+		b[k], b[k-1] = b[k-1], b[k]
+		gram[k], gram[k-1] = gram[k-1], gram[k]
+		for i := range gram {
+			gram[i][k], gram[i][k-1] = gram[i][k-1], gram[i][k]
+		}
+		if k > 1 {
+			k--
+		} else {
+			orthogonalise(0)
+		}
+	}
+	return b
+}
+
+// dotInt returns the dot product of a and b.
+func dotInt(a, b []*big.Int) *big.Int {
+	res := new(big.Int)
+	tmp := new(big.Int)
+	for i := range a {
+		res.Add(res, tmp.Mul(a[i], b[i]))
+	}
+	return res
+}
@@ -2,9 +2,24 @@ package mtsn
 
 import (
 	"testing"
+	"context"
 	"strconv"
 	"bytes"
 	"math"
+	"math/big"
+	"crypto/rand"
+	"net/http/httptest"
+	"sync"
+	"time"
+	"hash"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"md4hacks"
+	"sha1hacks"
+	"crypto/hmac"
+	"encoding/hex"
 )
 
 func TestPadPkcs7(t *testing.T) {
@@ -241,6 +256,96 @@ func TestSha1HMAC(t *testing.T) {
 	}
 }
 
+func TestHMAC(t *testing.T) {
+	// Test vectors from RFC 2202 and RFC 4231
+	hi := []byte("Hi There")
+	jefe := []byte("what do ya want for nothing?")
+	hashFirst := []byte("Test Using Larger Than Block-Size Key - Hash Key First")
+	vectors := []struct {
+		name    string
+		newHash func() hash.Hash
+		key     []byte
+		text    []byte
+		mac     string
+	}{
+		{"SHA-1", sha1hacks.New, bytes.Repeat([]byte{0x0b}, 20), hi,
+			"b617318655057264e28bc0b6fb378c8ef146be00"},
+		{"SHA-1", sha1hacks.New, []byte("Jefe"), jefe,
+			"effcdf6ae5eb2fa2d27416d5f184df9c259a7c79"},
+		{"SHA-1", sha1hacks.New, bytes.Repeat([]byte{0xaa}, 20), bytes.Repeat([]byte{0xdd}, 50),
+			"125d7342b9ac11cd91a39af48aa17b4f63f175d3"},
+		{"SHA-1", sha1hacks.New, bytes.Repeat([]byte{0xaa}, 80), hashFirst,
+			"aa4ae5e15272d00e95705637ce8a3b55ed402112"},
+		{"SHA-1", sha1hacks.New, bytes.Repeat([]byte{0xaa}, 80),
+			[]byte("Test Using Larger Than Block-Size Key and Larger Than One Block-Size Data"),
+			"e8e99d0f45237d786d6bbaa7965c7808bbff1a91"},
+		{"MD5", md5.New, bytes.Repeat([]byte{0x0b}, 16), hi,
+			"9294727a3638bb1c13f48ef8158bfc9d"},
+		{"MD5", md5.New, []byte("Jefe"), jefe,
+			"750c783e6ab0b503eaa86e310a5db738"},
+		{"SHA-256", sha256.New, bytes.Repeat([]byte{0x0b}, 20), hi,
+			"b0344c61d8db38535ca8afceaf0bf12b881dc200c9833da726e9376c2e32cff7"},
+		{"SHA-256", sha256.New, []byte("Jefe"), jefe,
+			"5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{"SHA-256", sha256.New, bytes.Repeat([]byte{0xaa}, 131), hashFirst,
+			"60e431591ee0b67f0d8a26aacbf5b77f8e0bc6213728c5140546040f0ee37f54"},
+		{"SHA-512", sha512.New, bytes.Repeat([]byte{0x0b}, 20), hi,
+			"87aa7cdea5ef619d4ff0b4241a1d6cb02379f4e2ce4ec2787ad0b30545e17cde" +
+				"daa833b7d6b8a702038b274eaea3f4e4be9d914eeb61f1702e696c203a126854"},
+		{"SHA-512", sha512.New, []byte("Jefe"), jefe,
+			"164b7a7bfcf819e2e395fbe73b56e0a387bd64222e831fd610270cd7ea250554" +
+				"9758bf75c05a994a6d034f65f8f0e6fdcaeab1a34d4a6b4b636e070a38bce737"},
+		{"SHA-512", sha512.New, bytes.Repeat([]byte{0xaa}, 131), hashFirst,
+			"80b24263c7c1a3ebb71493c1dd7be8b49b46d1f41b4aeec1121b013783f8f352" +
+				"6b56d037e05f2598bd0fd2215d6a1e5295e64f73f63f0aec8b915a985d786598"},
+	}
+
+	for i, v := range vectors {
+		mac := HMACSum(v.newHash, v.key, v.text)
+		if hex.EncodeToString(mac) != v.mac {
+			t.Errorf("%s vector %d: got %x, expected %s", v.name, i, mac, v.mac)
+		}
+
+		// Writing one byte at a time gives the same answer
+		h := NewHMAC(v.newHash, v.key)
+		for j := range v.text {
+			h.Write(v.text[j : j+1])
+		}
+		expected, _ := hex.DecodeString(v.mac)
+		if !h.Verify(expected) {
+			t.Errorf("%s vector %d did not verify when streamed", v.name, i)
+		}
+
+		expected[0] ^= 1
+		if h.Verify(expected) {
+			t.Errorf("%s vector %d verified a bad mac", v.name, i)
+		}
+	}
+}
+
+func TestHMACKeySizes(t *testing.T) {
+	// Keys around the block size, which Sha1HMAC used to get wrong
+	text := []byte("Some Text")
+	for keyLen := sha1hacks.BlockSize - 1; keyLen <= sha1hacks.BlockSize+1; keyLen++ {
+		key := bytes.Repeat([]byte("k"), keyLen)
+
+		expected := hmac.New(sha1.New, key)
+		expected.Write(text)
+		if mac := Sha1HMAC(key, text); !bytes.Equal(mac, expected.Sum(nil)) {
+			t.Errorf("Key of %d bytes: got %x, expected %x", keyLen, mac, expected.Sum(nil))
+		}
+	}
+
+	// Reset keeps the key
+	h := NewHMAC(sha256.New, []byte("key"))
+	h.Write([]byte("something else"))
+	h.Reset()
+	h.Write(text)
+	if !h.Verify(HMACSum(sha256.New, []byte("key"), text)) {
+		t.Errorf("Reset did not go back to a freshly keyed HMAC")
+	}
+}
+
 func TestGetByte(t *testing.T) {
 	base := 0xaa00bb
 
@@ -257,3 +362,849 @@ func TestGetByte(t *testing.T) {
 		t.Errorf("Got 0x%x for byte 3", GetByte(base, 3))
 	}
 }
+
+// Generates an RSA public key made out of p and q, using e as the public
+// exponent.
+func testPublicKey(t *testing.T, p, q, e *big.Int) *RSAPublicKey {
+	rsa, err := NewRSAFromPrimes(p, q, e)
+	if err != nil {
+		t.Fatalf("Cannot build key from %v and %v: %s", p, q, err)
+	}
+	return rsa.PublicKey()
+}
+
+func testPrime(t *testing.T, bits int) *big.Int {
+	p, err := rand.Prime(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestAuditRSAKeys(t *testing.T) {
+	e := big.NewInt(65537)
+	var keys []*RSAPublicKey
+
+	// Sound key
+	keys = append(keys, testPublicKey(t, testPrime(t, 256), testPrime(t, 256), e))
+
+	// Two keys sharing a prime
+	shared := testPrime(t, 256)
+	keys = append(keys, testPublicKey(t, shared, testPrime(t, 256), e))
+	keys = append(keys, testPublicKey(t, testPrime(t, 256), shared, e))
+
+	// Primes right next to each other
+	closePrime := testPrime(t, 256)
+	next := new(big.Int).Add(closePrime, big.NewInt(1000))
+	for !next.ProbablyPrime(20) {
+		next.Add(next, Big.Two)
+	}
+	keys = append(keys, testPublicKey(t, closePrime, next, e))
+
+	// p - 1 only has small factors
+	smooth := new(big.Int)
+	for {
+		smooth.Set(Big.Two)
+		for smooth.BitLen() < 256 {
+			smooth.Mul(smooth, big.NewInt(int64(RandomNumber(2, 1000))))
+		}
+		smooth.Add(smooth, Big.One)
+		if smooth.ProbablyPrime(20) {
+			break
+		}
+	}
+	keys = append(keys, testPublicKey(t, smooth, testPrime(t, 256), e))
+
+	// Tiny private exponent
+	p, q := testPrime(t, 256), testPrime(t, 256)
+	phi := new(big.Int).Mul(new(big.Int).Sub(p, Big.One), new(big.Int).Sub(q, Big.One))
+	d := big.NewInt(65537)
+	for new(big.Int).GCD(nil, nil, d, phi).Cmp(Big.One) != 0 {
+		d.Add(d, Big.Two)
+	}
+	smallD, err := InvMod(d, phi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys = append(keys, testPublicKey(t, p, q, smallD))
+
+	// A key sharing each of its primes with a different key, which makes
+	// BatchGCD give back the whole modulus
+	p, q = testPrime(t, 256), testPrime(t, 256)
+	keys = append(keys, testPublicKey(t, p, q, e))
+	keys = append(keys, testPublicKey(t, p, testPrime(t, 256), e))
+	keys = append(keys, testPublicKey(t, testPrime(t, 256), q, e))
+
+	// The same modulus twice
+	duplicate := testPublicKey(t, testPrime(t, 256), testPrime(t, 256), e)
+	keys = append(keys, duplicate, &RSAPublicKey{duplicate.N, duplicate.E})
+
+	// Private exponent past Wiener's n**0.25, but within Boneh-Durfee's reach
+	p, q = testPrime(t, 256), testPrime(t, 256)
+	phi = new(big.Int).Mul(new(big.Int).Sub(p, Big.One), new(big.Int).Sub(q, Big.One))
+	d = new(big.Int).Lsh(Big.One, 131)
+	for new(big.Int).GCD(nil, nil, d, phi).Cmp(Big.One) != 0 {
+		d.Add(d, Big.One)
+	}
+	mediumD, err := InvMod(d, phi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys = append(keys, testPublicKey(t, p, q, mediumD))
+
+	expected := map[int]string{
+		1:  ATTACK_BATCH_GCD,
+		2:  ATTACK_BATCH_GCD,
+		3:  ATTACK_FERMAT,
+		4:  ATTACK_POLLARD,
+		5:  ATTACK_WIENER,
+		6:  ATTACK_BATCH_GCD,
+		7:  ATTACK_BATCH_GCD,
+		8:  ATTACK_BATCH_GCD,
+		9:  ATTACK_DUPLICATE,
+		10: ATTACK_DUPLICATE,
+		11: ATTACK_BONEH_DURFEE,
+	}
+
+	results := AuditRSAKeys(keys)
+	if len(results) != len(expected) {
+		t.Errorf("Expected %d broken keys, got %d", len(expected), len(results))
+	}
+
+	msg := []byte("audit")
+	for _, result := range results {
+		if expected[result.Index] != result.Attack {
+			t.Errorf("Key %d broken by %q, expected %q",
+				result.Index, result.Attack, expected[result.Index])
+		}
+		if result.Attack == ATTACK_DUPLICATE {
+			if result.D != nil || result.Private() != nil {
+				t.Errorf("Key %d has a private exponent for a duplicate modulus", result.Index)
+			}
+			continue
+		}
+		decrypted := result.Private().Decrypt(result.Key.Encrypt(msg))
+		if !bytes.Equal(msg, decrypted) {
+			t.Errorf("Key %d decrypted %q", result.Index, decrypted)
+		}
+	}
+}
+
+func TestResultant(t *testing.T) {
+	// Resultants in x of x - y and x + y - 4, and of x**2 - y and x - 2
+	line := newBiPoly(1, 1)
+	line[1][0].SetInt64(1)
+	line[0][1].SetInt64(-1)
+	other := newBiPoly(1, 1)
+	other[1][0].SetInt64(1)
+	other[0][1].SetInt64(1)
+	other[0][0].SetInt64(-4)
+	parabola := newBiPoly(2, 1)
+	parabola[2][0].SetInt64(1)
+	parabola[0][1].SetInt64(-1)
+	vertical := newBiPoly(1, 0)
+	vertical[1][0].SetInt64(1)
+	vertical[0][0].SetInt64(-2)
+
+	tests := []struct {
+		p, q     biPoly
+		expected Poly
+	}{
+		{line, other, NewPoly(-4, 2)},
+		{parabola, vertical, NewPoly(4, -1)},
+	}
+	for _, test := range tests {
+		res := resultantX(test.p, test.q)
+		if len(res) != len(test.expected) {
+			t.Errorf("Got resultant %v, expected %v", res, test.expected)
+			continue
+		}
+		for i := range res {
+			if res[i].Cmp(test.expected[i]) != 0 {
+				t.Errorf("Got resultant %v, expected %v", res, test.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestCRT(t *testing.T) {
+	residues := []*big.Int{big.NewInt(2), big.NewInt(3), big.NewInt(2)}
+	moduli := []*big.Int{big.NewInt(3), big.NewInt(5), big.NewInt(7)}
+	x, m, err := CRT(residues, moduli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.Int64() != 23 || m.Int64() != 105 {
+		t.Errorf("Got %v mod %v, expected 23 mod 105", x, m)
+	}
+
+	// Moduli sharing a factor
+	residues = []*big.Int{big.NewInt(3), big.NewInt(7)}
+	moduli = []*big.Int{big.NewInt(4), big.NewInt(6)}
+	x, m, err = CRT(residues, moduli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.Int64() != 7 || m.Int64() != 12 {
+		t.Errorf("Got %v mod %v, expected 7 mod 12", x, m)
+	}
+
+	residues = []*big.Int{big.NewInt(1), big.NewInt(2)}
+	_, _, err = CRT(residues, moduli)
+	if err == nil {
+		t.Errorf("Contradicting congruences did not error")
+	}
+}
+
+func TestRoot(t *testing.T) {
+	for _, k := range []int{1, 2, 3, 5, 17} {
+		for i := 0; i < 50; i++ {
+			n, _ := rand.Int(rand.Reader, new(big.Int).Lsh(Big.One, uint(RandomNumber(1, 2048))))
+			x := Root(n, k)
+
+			kBig := big.NewInt(int64(k))
+			below := new(big.Int).Exp(x, kBig, nil)
+			above := new(big.Int).Exp(new(big.Int).Add(x, Big.One), kBig, nil)
+			if below.Cmp(n) > 0 || above.Cmp(n) <= 0 {
+				t.Fatalf("Root(%v, %d) gave %v", n, k, x)
+			}
+		}
+	}
+}
+
+// Builds count keys with exponent e, using primes of the given size.
+func testBroadcastKeys(t *testing.T, count int, e *big.Int, bits int) []*RSAPublicKey {
+	keys := make([]*RSAPublicKey, 0, count)
+	for len(keys) < count {
+		rsa, err := NewRSAFromPrimes(testPrime(t, bits), testPrime(t, bits), e)
+		if err == nil {
+			keys = append(keys, rsa.PublicKey())
+		}
+	}
+	return keys
+}
+
+func TestHastadBroadcast(t *testing.T) {
+	msg := []byte("broadcast secret")
+
+	for _, e := range []int64{3, 5} {
+		keys := testBroadcastKeys(t, int(e), big.NewInt(e), 256)
+		ciphertexts := make([]*big.Int, len(keys))
+		for i, key := range keys {
+			ciphertexts[i] = key.Encrypt(msg)
+		}
+
+		m, err := HastadBroadcast(keys, ciphertexts)
+		if err != nil {
+			t.Fatalf("e=%d: %s", e, err)
+		}
+		if !bytes.Equal(msg, m.Bytes()) {
+			t.Errorf("e=%d: decrypted %q", e, m.Bytes())
+		}
+	}
+}
+
+func TestHastadPadded(t *testing.T) {
+	msg := new(big.Int).SetBytes([]byte("padded broadcast secret"))
+	keys := testBroadcastKeys(t, 3, Big.Three, 256)
+
+	ciphertexts := make([]*big.Int, len(keys))
+	pads := make([]*LinearPad, len(keys))
+	for i, key := range keys {
+		// Each message is prefixed with its index, i.e. i * 2**256 + m
+		pads[i] = &LinearPad{A: big.NewInt(1), B: new(big.Int).Lsh(big.NewInt(int64(i+1)), 256)}
+		padded := new(big.Int).Mul(pads[i].A, msg)
+		padded.Add(padded, pads[i].B)
+		ciphertexts[i] = key.Encrypt(padded.Bytes())
+	}
+
+	// Plain Hastad cannot do anything with this
+	if m, err := HastadBroadcast(keys, ciphertexts); err == nil && m.Cmp(msg) == 0 {
+		t.Errorf("Plain broadcast attack worked on padded messages")
+	}
+
+	m, err := HastadPadded(keys, ciphertexts, pads, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Cmp(msg) != 0 {
+		t.Errorf("Decrypted %q", m.Bytes())
+	}
+}
+
+// Slow but obviously right floor of the k-th root, set one bit at a time.
+func bitwiseRoot(n *big.Int, k int) *big.Int {
+	root := new(big.Int)
+	kBig := big.NewInt(int64(k))
+	for i := n.BitLen()/k + 1; i >= 0; i-- {
+		root.SetBit(root, i, 1)
+		if new(big.Int).Exp(root, kBig, nil).Cmp(n) > 0 {
+			root.SetBit(root, i, 0)
+		}
+	}
+	return root
+}
+
+func FuzzRoot(f *testing.F) {
+	f.Add([]byte{0}, uint8(3))
+	f.Add([]byte{8}, uint8(3))
+	f.Add([]byte{0x1b}, uint8(3))
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff}, uint8(2))
+	f.Add([]byte("a much longer number to take the root of"), uint8(7))
+
+	f.Fuzz(func(t *testing.T, data []byte, k uint8) {
+		n := new(big.Int).SetBytes(data)
+		if k == 0 {
+			k = 1
+		}
+
+		root, rem := RootRem(n, int(k))
+		if expected := bitwiseRoot(n, int(k)); root.Cmp(expected) != 0 {
+			t.Fatalf("Root(%v, %d) = %v, expected %v", n, k, root, expected)
+		}
+		if rem.Sign() < 0 {
+			t.Fatalf("RootRem(%v, %d) has negative remainder %v", n, k, rem)
+		}
+
+		// Exact powers have to come back exactly
+		power := new(big.Int).Exp(n, big.NewInt(int64(k)), nil)
+		root, rem = RootRem(power, int(k))
+		if root.Cmp(n) != 0 || rem.Sign() != 0 {
+			t.Fatalf("RootRem(%v**%d) = %v rem %v", n, k, root, rem)
+		}
+	})
+}
+
+func TestRSAOracle(t *testing.T) {
+	now := time.Now()
+	oracle := NewRSAOracle(NewRSA(), time.Minute)
+	oracle.Now = func() time.Time { return now }
+
+	msg := []byte("only once")
+	key := oracle.PublicKey()
+	encrypted := key.Encrypt(msg)
+
+	decrypted, err := oracle.Decrypt(encrypted)
+	if err != nil || !bytes.Equal(msg, decrypted) {
+		t.Fatalf("First decryption got %q, %v", decrypted, err)
+	}
+	if _, err = oracle.Decrypt(encrypted); err == nil {
+		t.Errorf("Second decryption worked")
+	}
+	if _, err = oracle.Decrypt(new(big.Int).Add(encrypted, key.N)); err == nil {
+		t.Errorf("Decryption of c + n worked")
+	}
+
+	now = now.Add(time.Minute)
+	if _, err = oracle.Decrypt(encrypted); err != nil {
+		t.Errorf("Decryption after the ttl failed: %s", err)
+	}
+
+	// Hammer it from many goroutines, exactly one of them should get in
+	encrypted = key.Encrypt([]byte("race"))
+	var wg sync.WaitGroup
+	successes := make(chan bool, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := oracle.Decrypt(encrypted)
+			successes <- err == nil
+		}()
+	}
+	wg.Wait()
+	close(successes)
+
+	count := 0
+	for success := range successes {
+		if success {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("%d goroutines decrypted the same ciphertext", count)
+	}
+}
+
+func TestBlindingAttack(t *testing.T) {
+	oracle := NewRSAOracle(NewRSA(), 0)
+	server := httptest.NewServer(oracle)
+	defer server.Close()
+	client := &RSAOracleClient{URL: server.URL}
+
+	msg := []byte("over the wire")
+	key := oracle.PublicKey()
+	encrypted := key.Encrypt(msg)
+	if _, err := client.Decrypt(encrypted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Decrypt(encrypted); err == nil {
+		t.Fatalf("Remote oracle decrypted twice")
+	}
+
+	cracked, err := BlindingAttack(client.Decrypt, key, encrypted, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg, cracked) {
+		t.Errorf("Cracked %q", cracked)
+	}
+
+	// Any exponent will do
+	rsa, err := NewRSAFromPrimes(testPrime(t, 256), testPrime(t, 256), big.NewInt(65537))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oracle = NewRSAOracle(rsa, 0)
+	key = oracle.PublicKey()
+	encrypted = key.Encrypt(msg)
+	oracle.Decrypt(encrypted)
+
+	cracked, err = BlindingAttack(oracle.Decrypt, key, encrypted, big.NewInt(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg, cracked) {
+		t.Errorf("Cracked %q with e=65537", cracked)
+	}
+}
+
+func TestPolyGCD(t *testing.T) {
+	n := big.NewInt(101)
+	// (x - 3)(x - 5) and (x - 3)(x + 7)
+	a := NewPoly(-3, 1).Mul(NewPoly(-5, 1))
+	b := NewPoly(-3, 1).Mul(NewPoly(7, 1))
+
+	gcd, err := a.GCD(b, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gcd.Degree() != 1 || gcd[0].Int64() != 98 || gcd[1].Int64() != 1 {
+		t.Errorf("Got gcd %v", gcd)
+	}
+
+	quotient, rem, err := a.DivMod(NewPoly(-3, 1), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rem.Degree() != -1 || quotient.Degree() != 1 || quotient[0].Int64() != 96 {
+		t.Errorf("Got quotient %v and remainder %v", quotient, rem)
+	}
+}
+
+func TestCommonModulus(t *testing.T) {
+	n := NewRSAWithExponent(256, big.NewInt(65537)).PublicKey().N
+	msg := []byte("same modulus")
+
+	for _, exponents := range [][2]int64{{3, 65537}, {6, 9}} {
+		key1 := &RSAPublicKey{n, big.NewInt(exponents[0])}
+		key2 := &RSAPublicKey{n, big.NewInt(exponents[1])}
+
+		m, err := CommonModulus(key1, key2, key1.Encrypt(msg), key2.Encrypt(msg))
+		if err != nil {
+			t.Fatalf("Exponents %v: %s", exponents, err)
+		}
+		if !bytes.Equal(msg, m.Bytes()) {
+			t.Errorf("Exponents %v: recovered %q", exponents, m.Bytes())
+		}
+	}
+}
+
+func TestFranklinReiter(t *testing.T) {
+	for _, e := range []int64{3, 5} {
+		rsa := NewRSAWithExponent(256, big.NewInt(e))
+		key := rsa.PublicKey()
+
+		m1 := new(big.Int).SetBytes([]byte("related message"))
+		pad := &LinearPad{A: big.NewInt(2), B: big.NewInt(42)}
+		m2 := new(big.Int).Mul(m1, pad.A)
+		m2.Add(m2, pad.B)
+
+		m, err := FranklinReiter(key, key.Encrypt(m1.Bytes()), key.Encrypt(m2.Bytes()), pad)
+		if err != nil {
+			t.Fatalf("e=%d: %s", e, err)
+		}
+		if m.Cmp(m1) != 0 {
+			t.Errorf("e=%d: recovered %q", e, m.Bytes())
+		}
+	}
+}
+
+func TestLengthExtension(t *testing.T) {
+	newHashes := map[*ExtendableHash]func() hash.Hash{
+		ExtendSHA1:   sha1.New,
+		ExtendMD4:    md4hacks.New,
+		ExtendMD5:    md5.New,
+		ExtendSHA256: sha256.New,
+		ExtendSHA512: sha512.New,
+	}
+	key := []byte("YELLOW SUBMARINE")
+	msg := []byte("comment1=cooking%20MCs;userdata=foo;comment2=%20like%20a%20pound%20of%20bacon")
+	suffix := []byte(";admin=true")
+
+	mac := func(newHash func() hash.Hash, text []byte) []byte {
+		h := newHash()
+		h.Write(key)
+		h.Write(text)
+		return h.Sum(nil)
+	}
+
+	for h, newHash := range newHashes {
+		newMsg, newDigest, err := LengthExtension(h, mac(newHash, msg), msg, len(key), suffix)
+		if err != nil {
+			t.Fatalf("%s: %s", h.Name, err)
+		}
+		if !bytes.HasSuffix(newMsg, suffix) || !bytes.HasPrefix(newMsg, msg) {
+			t.Errorf("%s: forged message %q", h.Name, newMsg)
+		}
+		if !bytes.Equal(mac(newHash, newMsg), newDigest) {
+			t.Errorf("%s: forged digest does not match", h.Name)
+		}
+
+		verify := func(text []byte, digest []byte) bool {
+			return bytes.Equal(mac(newHash, text), digest)
+		}
+		keyLen, _, _, err := LengthExtensionSearch(h, mac(newHash, msg), msg, suffix, 32, verify)
+		if err != nil || keyLen != len(key) {
+			t.Errorf("%s: search found key length %d, %v", h.Name, keyLen, err)
+		}
+	}
+}
+
+func TestToyHash(t *testing.T) {
+	h := NewToyHash(16)
+	msg := []byte("A message which is longer than one block")
+
+	full := len(msg) - len(msg)%TOY_BLOCK_SIZE
+	state := h.Iterate(h.IV, msg[:full])
+	padded := append(append([]byte{}, msg[full:]...), h.Padding(len(msg))...)
+	if h.Sum(msg) != h.Iterate(state, padded) {
+		t.Errorf("Sum does not match iterating over the padded message")
+	}
+	if h.Sum(msg) > 0xffff {
+		t.Errorf("Digest %x is more than 16 bits", h.Sum(msg))
+	}
+
+	x, y, next := h.Collision(h.IV)
+	if bytes.Equal(x, y) || h.Compress(h.IV, x) != next || h.Compress(h.IV, y) != next {
+		t.Errorf("Bad collision %x and %x", x, y)
+	}
+}
+
+func TestJouxMulticollision(t *testing.T) {
+	h := NewToyHash(16)
+	pairs, _ := JouxMulticollision(h, h.IV, 4)
+	messages := JouxMessages(pairs)
+	if len(messages) != 16 {
+		t.Fatalf("Expected 16 messages, got %d", len(messages))
+	}
+
+	seen := make(map[string]bool)
+	for _, msg := range messages {
+		if h.Sum(msg) != h.Sum(messages[0]) {
+			t.Errorf("%x hashes to %x, not %x", msg, h.Sum(msg), h.Sum(messages[0]))
+		}
+		seen[string(msg)] = true
+	}
+	if len(seen) != len(messages) {
+		t.Errorf("Only %d different messages out of %d", len(seen), len(messages))
+	}
+}
+
+func TestCascadeCollision(t *testing.T) {
+	f := NewToyHash(16)
+	g := NewToyHash(24)
+	g.IV = 0xabcdef
+
+	x, y := CascadeCollision(f, g)
+	if bytes.Equal(x, y) {
+		t.Fatalf("Cascade collision is the same message twice")
+	}
+	if f.Sum(x) != f.Sum(y) || g.Sum(x) != g.Sum(y) {
+		t.Errorf("%x and %x do not collide under both hashes", x, y)
+	}
+}
+
+func TestSecondPreimage(t *testing.T) {
+	h := NewToyHash(20)
+	k := 8
+	target := make([]byte, TOY_BLOCK_SIZE<<uint(k))
+	for i := range target {
+		target[i] = byte(i * 7)
+	}
+	// An odd tail, which SecondPreimage leaves alone
+	target = append(target, "the end"...)
+
+	e := NewExpandableMessage(h, h.IV, 3)
+	for blocks := 3; blocks < 3+8; blocks++ {
+		msg, err := e.Expand(blocks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msg) != blocks*TOY_BLOCK_SIZE || h.Iterate(h.IV, msg) != e.State {
+			t.Errorf("Expanding to %d blocks did not give a good message", blocks)
+		}
+	}
+	if _, err := e.Expand(3 + 8); err == nil {
+		t.Errorf("Expanding past the end worked")
+	}
+
+	forged, err := SecondPreimage(h, target, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(forged, target) || len(forged) != len(target) {
+		t.Fatalf("Forged message is not a different message of the same length")
+	}
+	if h.Sum(forged) != h.Sum(target) {
+		t.Errorf("Forged message hashes to %x, not %x", h.Sum(forged), h.Sum(target))
+	}
+
+	if _, err := SecondPreimage(h, target[:TOY_BLOCK_SIZE*k], k); err == nil {
+		t.Errorf("Second preimage of a too short message worked")
+	}
+}
+
+func TestHerding(t *testing.T) {
+	h := NewToyHash(16)
+	d := NewDiamond(h, 6)
+	prediction := d.Predict(2 * TOY_BLOCK_SIZE)
+
+	prefix := []byte("Red Sox win 3-1, Yankees lose 0-4")[:2*TOY_BLOCK_SIZE]
+	msg, err := d.Herd(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(msg, prefix) {
+		t.Errorf("Herded message %q does not start with the prefix", msg)
+	}
+	if h.Sum(msg) != prediction {
+		t.Errorf("Herded message hashes to %x, not the prediction %x", h.Sum(msg), prediction)
+	}
+
+	if _, err := d.Herd([]byte("short")); err == nil {
+		t.Errorf("Herding from an unaligned prefix worked")
+	}
+}
+
+func TestCBCMAC(t *testing.T) {
+	key := GenerateRandomKey()
+	iv := make([]byte, 16)
+
+	msg1 := []byte("from=alice&tx_list=bob:10;carol:20")
+	msg2 := []byte("from=mallory&tx_list=mallory:1000000")
+	mac1, _ := CBCMAC(key, iv, msg1)
+	mac2, _ := CBCMAC(key, iv, msg2)
+
+	forged, err := CBCMACExtend(iv, msg1, mac1, msg2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(forged, msg1) {
+		t.Errorf("Extended message %q does not start with the first message", forged)
+	}
+	if mac, _ := CBCMAC(key, iv, forged); !bytes.Equal(mac, mac2) {
+		t.Errorf("Extended message has MAC %x, not %x", mac, mac2)
+	}
+
+	// Changing the first block along with the IV
+	iv = GenerateRandomKey()
+	msg := []byte("from=mallory&to=bob&amount=1000000")
+	mac, _ := CBCMAC(key, iv, msg)
+	forged, newIV, err := CBCMACForgeIV(iv, msg, []byte("from=alice&to=m&"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(forged, []byte("from=alice&to=m&")) {
+		t.Errorf("Forged message %q does not start with the new block", forged)
+	}
+	if forgedMac, _ := CBCMAC(key, newIV, forged); !bytes.Equal(mac, forgedMac) {
+		t.Errorf("Forged message has MAC %x, not %x", forgedMac, mac)
+	}
+}
+
+func TestCBCMACSecondPreimage(t *testing.T) {
+	key := []byte("YELLOW SUBMARINE")
+	iv := make([]byte, 16)
+	target := []byte("alert('MZA who was that?');\n")
+
+	expected, _ := CBCMAC(key, iv, target)
+	if hex.EncodeToString(expected) != "296b8d7cb78a243dda4d0a61d33bbdd1" {
+		t.Errorf("Hash of the snippet is %x", expected)
+	}
+
+	prefix := []byte("alert('Ayo, the Wu is back!');//")
+	forged, err := CBCMACSecondPreimage(key, iv, target, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(forged, prefix) {
+		t.Errorf("Forged snippet %q does not start with the prefix", forged)
+	}
+	if mac, _ := CBCMAC(key, iv, forged); !bytes.Equal(mac, expected) {
+		t.Errorf("Forged snippet hashes to %x, not %x", mac, expected)
+	}
+}
+
+func TestCompressionAttack(t *testing.T) {
+	secret := []byte("TmV2ZXIgcmV2ZWFsIHRoZSBXdS1UYW5nIFNlY3JldCE=")
+
+	for _, cbc := range []bool{false, true} {
+		oracle := &CompressionOracle{Secret: secret, CBC: cbc}
+		blockSize := 1
+		if cbc {
+			blockSize = 16
+		}
+		if !bytes.Contains(oracle.Request([]byte("body")), []byte("sessionid="+string(secret))) {
+			t.Errorf("Request does not carry the secret")
+		}
+
+		found, err := CompressionAttack(oracle.Length, []byte("sessionid="), BASE64_ALPHABET, len(secret), blockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(found, secret) {
+			t.Errorf("CBC %v: recovered %q instead of %q", cbc, found, secret)
+		}
+	}
+}
+
+// dlogGroup builds p = k*q + 1 with q a prime of qBits bits, and g of order q.
+func dlogGroup(t *testing.T, qBits int) (*big.Int, *big.Int, *big.Int) {
+	q, err := rand.Prime(rand.Reader, qBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		k, err := rand.Int(rand.Reader, new(big.Int).Lsh(Big.One, 32))
+		if err != nil {
+			t.Fatal(err)
+		}
+		k.Lsh(k, 1)
+		p := new(big.Int).Mul(k, q)
+		p.Add(p, Big.One)
+		if !p.ProbablyPrime(20) {
+			continue
+		}
+		for h := int64(2); ; h++ {
+			g := new(big.Int).Exp(big.NewInt(h), k, p)
+			if g.Cmp(Big.One) != 0 {
+				return p, g, q
+			}
+		}
+	}
+}
+
+func TestSmallFactors(t *testing.T) {
+	n := big.NewInt(2 * 2 * 3 * 7 * 7 * 7 * 101 * 65537)
+	factors := SmallFactors(n, 1000)
+	want := []int64{2, 3, 7, 101}
+	if len(factors) != len(want) {
+		t.Fatalf("Got factors %v, want %v", factors, want)
+	}
+	for i, f := range factors {
+		if f.Int64() != want[i] {
+			t.Errorf("Factor %d is %v, want %d", i, f, want[i])
+		}
+	}
+}
+
+func TestKangaroo(t *testing.T) {
+	p, g, _ := dlogGroup(t, 64)
+	ctx := context.Background()
+
+	a := big.NewInt(1 << 50)
+	for _, bits := range []uint{8, 20, 40} {
+		b := new(big.Int).Add(a, new(big.Int).Lsh(Big.One, bits))
+		offset, _ := rand.Int(rand.Reader, new(big.Int).Lsh(Big.One, bits))
+		secrets := []*big.Int{new(big.Int).Add(a, offset)}
+		if bits < 40 {
+			secrets = append(secrets, a, b)
+		}
+		for _, secret := range secrets {
+			y := new(big.Int).Exp(g, secret, p)
+			x, err := Kangaroo(ctx, g, y, p, a, b, 0)
+			if err != nil {
+				t.Errorf("Interval of 2^%d: %v", bits, err)
+				continue
+			}
+			if x.Cmp(secret) != 0 {
+				t.Errorf("Interval of 2^%d: found %v instead of %v", bits, x, secret)
+			}
+		}
+	}
+
+	// Out of the interval, the search gives up
+	y := new(big.Int).Exp(g, big.NewInt(12345), p)
+	if x, err := Kangaroo(ctx, g, y, p, a, new(big.Int).Add(a, big.NewInt(1<<16)), 0); err == nil {
+		t.Errorf("Found %v for a log outside of the interval", x)
+	}
+	if _, err := Kangaroo(ctx, g, y, p, a, big.NewInt(0), 0); err == nil {
+		t.Errorf("Should refuse an empty interval")
+	}
+}
+
+func TestPollardRho(t *testing.T) {
+	ctx := context.Background()
+	for _, bits := range []int{16, 40} {
+		p, g, q := dlogGroup(t, bits)
+		secret, _ := rand.Int(rand.Reader, q)
+		y := new(big.Int).Exp(g, secret, p)
+
+		x, err := PollardRho(ctx, g, y, p, q, 0)
+		if err != nil {
+			t.Errorf("Order of %d bits: %v", bits, err)
+			continue
+		}
+		if x.Cmp(secret) != 0 {
+			t.Errorf("Order of %d bits: found %v instead of %v", bits, x, secret)
+		}
+	}
+}
+
+func TestDlogLimits(t *testing.T) {
+	p, g, q := dlogGroup(t, 60)
+	secret, _ := rand.Int(rand.Reader, q)
+	y := new(big.Int).Exp(g, secret, p)
+	a := big.NewInt(0)
+	b := new(big.Int).Lsh(Big.One, 56)
+
+	if _, err := Kangaroo(context.Background(), g, y, p, a, b, 1000); err == nil {
+		t.Errorf("Kangaroo should run out of budget")
+	}
+	if _, err := PollardRho(context.Background(), g, y, p, q, 1000); err == nil {
+		t.Errorf("PollardRho should run out of budget")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := Kangaroo(ctx, g, y, p, a, b, 0); err != context.DeadlineExceeded {
+		t.Errorf("Kangaroo gave %v instead of stopping at the deadline", err)
+	}
+	if _, err := PollardRho(ctx, g, y, p, q, 0); err != context.DeadlineExceeded {
+		t.Errorf("PollardRho gave %v instead of stopping at the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Took %v to notice the deadline", elapsed)
+	}
+}
+
+func TestLLL(t *testing.T) {
+	basis := [][]*big.Int{
+		{big.NewInt(1), big.NewInt(1), big.NewInt(1)},
+		{big.NewInt(-1), big.NewInt(0), big.NewInt(2)},
+		{big.NewInt(3), big.NewInt(5), big.NewInt(6)},
+	}
+	expected := [][]int64{{0, 1, 0}, {1, 0, 1}, {-1, 0, 2}}
+
+	reduced := LLL(basis, nil)
+	for i, row := range reduced {
+		for j, v := range row {
+			if v.Int64() != expected[i][j] {
+				t.Fatalf("Got reduced basis %v", reduced)
+			}
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package mtsn
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// JouxMulticollision chains t collisions together starting from state. Any
+// message made by picking one block out of each pair leads to the same final
+// state, which gives 2**t colliding messages for the price of t birthday
+// searches.
+func JouxMulticollision(h *ToyHash, state uint64, t int) ([][2][]byte, uint64) {
+	pairs := make([][2][]byte, t)
+	for i := range pairs {
+		x, y, next := h.Collision(state)
+		pairs[i] = [2][]byte{x, y}
+		state = next
+	}
+	return pairs, state
+}
+
+// JouxMessages lists every message a multicollision from JouxMulticollision
+// stands for.
+func JouxMessages(pairs [][2][]byte) [][]byte {
+	messages := [][]byte{{}}
+	for _, pair := range pairs {
+		next := make([][]byte, 0, 2*len(messages))
+		for _, msg := range messages {
+			for _, block := range pair {
+				next = append(next, append(append([]byte{}, msg...), block...))
+			}
+		}
+		messages = next
+	}
+	return messages
+}
+
+// CascadeCollision finds two messages colliding under both f and g, which
+// shows that concatenating f(x) || g(x) is only as strong as the stronger of
+// the two. It builds a multicollision in f big enough to expect a birthday
+// collision in g among its messages, adding more blocks until one turns up.
+func CascadeCollision(f *ToyHash, g *ToyHash) ([]byte, []byte) {
+	t := int(g.Bits/2) + 1
+	pairs, state := JouxMulticollision(f, f.IV, t)
+	for {
+		seen := make(map[uint64][]byte)
+		for _, msg := range JouxMessages(pairs) {
+			digest := g.Sum(msg)
+			if other, found := seen[digest]; found {
+				return other, msg
+			}
+			seen[digest] = msg
+		}
+
+		more, next := JouxMulticollision(f, state, 1)
+		pairs = append(pairs, more...)
+		state = next
+	}
+}
+
+// ExpandableMessage is a set of Kelsey–Schneier pieces, which can be put
+// together into a message of any number of blocks between k and k + 2**k - 1
+// that always ends on the same state.
+type ExpandableMessage struct {
+	Hash  *ToyHash
+	State uint64
+
+	// Piece i is a choice between a single block or 2**i + 1 blocks
+	short [][]byte
+	long  [][]byte
+}
+
+// NewExpandableMessage builds an expandable message of k pieces starting
+// from state.
+func NewExpandableMessage(h *ToyHash, state uint64, k int) *ExpandableMessage {
+	e := &ExpandableMessage{Hash: h, short: make([][]byte, k), long: make([][]byte, k)}
+
+	// Build the biggest piece first, so that the message is laid out from
+	// the longest choice to the shortest.
+	for i := k - 1; i >= 0; i-- {
+		dummy := make([]byte, TOY_BLOCK_SIZE<<uint(i))
+		dummyState := h.Iterate(state, dummy)
+
+		x, y, next := h.CollideFrom(state, dummyState)
+		e.short[i] = x
+		e.long[i] = append(dummy, y...)
+		state = next
+	}
+	e.State = state
+	return e
+}
+
+// Pieces is the number of pieces in the expandable message.
+func (e *ExpandableMessage) Pieces() int {
+	return len(e.short)
+}
+
+// Expand returns a message of blocks blocks, which leads to e.State.
+func (e *ExpandableMessage) Expand(blocks int) ([]byte, error) {
+	k := e.Pieces()
+	extra := blocks - k
+	if extra < 0 || extra >= 1<<uint(k) {
+		return nil, fmt.Errorf("Expandable message can only be %d to %d blocks long, not %d", k, k+1<<uint(k)-1, blocks)
+	}
+
+	var msg []byte
+	for i := k - 1; i >= 0; i-- {
+		if extra&(1<<uint(i)) != 0 {
+			msg = append(msg, e.long[i]...)
+		} else {
+			msg = append(msg, e.short[i]...)
+		}
+	}
+	return msg, nil
+}
+
+// SecondPreimage finds a different message of the same length as target,
+// with the same digest, using a Kelsey–Schneier expandable message of k
+// pieces. target must be at least k + 1 blocks long, and the attack works
+// best when it is about 2**k blocks long.
+func SecondPreimage(h *ToyHash, target []byte, k int) ([]byte, error) {
+	blocks := len(target) / TOY_BLOCK_SIZE
+	if blocks < k+1 {
+		return nil, fmt.Errorf("Target must be at least %d blocks long, not %d", k+1, blocks)
+	}
+
+	// Every state the target goes through which a bridge could land on
+	reachable := make(map[uint64]int)
+	state := h.IV
+	for i := 1; i <= blocks; i++ {
+		state = h.Compress(state, target[(i-1)*TOY_BLOCK_SIZE:i*TOY_BLOCK_SIZE])
+		if i >= k+1 && i <= k+1<<uint(k) {
+			if _, found := reachable[state]; !found {
+				reachable[state] = i
+			}
+		}
+	}
+
+	e := NewExpandableMessage(h, h.IV, k)
+	for {
+		bridge := GenerateRandomKey()
+		i, found := reachable[h.Compress(e.State, bridge)]
+		if !found {
+			continue
+		}
+
+		prefix, err := e.Expand(i - 1)
+		if err != nil {
+			return nil, err
+		}
+		forged := append(prefix, bridge...)
+		forged = append(forged, target[i*TOY_BLOCK_SIZE:]...)
+		if !bytes.Equal(forged, target) {
+			return forged, nil
+		}
+	}
+}
+
+// Diamond is the tree of collisions used by the Nostradamus (herding)
+// attack: 2**k leaf states, collided in pairs level by level until they all
+// lead to a single root.
+type Diamond struct {
+	Hash *ToyHash
+
+	// states[0] are the leaves, and the last level only holds the root.
+	// blocks[l][i] takes states[l][i] to states[l+1][i/2].
+	states [][]uint64
+	blocks [][][]byte
+}
+
+// NewDiamond builds a diamond with 2**k leaves.
+func NewDiamond(h *ToyHash, k int) *Diamond {
+	d := &Diamond{Hash: h}
+
+	level := make([]uint64, 1<<uint(k))
+	seen := make(map[uint64]bool)
+	for i := range level {
+		// Distinct leaves, or the tree wastes some of its width
+		for {
+			level[i] = h.Compress(h.IV, GenerateRandomKey())
+			if !seen[level[i]] {
+				break
+			}
+		}
+		seen[level[i]] = true
+	}
+
+	for len(level) > 1 {
+		next := make([]uint64, len(level)/2)
+		blocks := make([][]byte, len(level))
+		for i := range next {
+			x, y, state := h.CollideFrom(level[2*i], level[2*i+1])
+			blocks[2*i], blocks[2*i+1] = x, y
+			next[i] = state
+		}
+		d.states = append(d.states, level)
+		d.blocks = append(d.blocks, blocks)
+		level = next
+	}
+	d.states = append(d.states, level)
+	return d
+}
+
+// Depth is the number of blocks between a leaf and the root.
+func (d *Diamond) Depth() int {
+	return len(d.states) - 1
+}
+
+// Predict returns the digest every message herded from a prefix of
+// prefixLen bytes will have. It can be published before the prefix is known.
+func (d *Diamond) Predict(prefixLen int) uint64 {
+	root := d.states[len(d.states)-1][0]
+	return d.Hash.Finish(root, d.messageLength(prefixLen))
+}
+
+// messageLength is the length of a message herded from a prefix of
+// prefixLen bytes: the prefix, one linking block and a path to the root.
+func (d *Diamond) messageLength(prefixLen int) int {
+	return prefixLen + TOY_BLOCK_SIZE*(1+d.Depth())
+}
+
+// Herd finds a message starting with prefix whose digest is
+// Predict(len(prefix)). prefix must be a whole number of blocks long.
+func (d *Diamond) Herd(prefix []byte) ([]byte, error) {
+	if len(prefix)%TOY_BLOCK_SIZE != 0 {
+		return nil, fmt.Errorf("Prefix must be a whole number of blocks, not %d bytes", len(prefix))
+	}
+
+	leaves := make(map[uint64]int)
+	for i, leaf := range d.states[0] {
+		leaves[leaf] = i
+	}
+
+	state := d.Hash.Iterate(d.Hash.IV, prefix)
+	for {
+		link := GenerateRandomKey()
+		i, found := leaves[d.Hash.Compress(state, link)]
+		if !found {
+			continue
+		}
+
+		msg := append(append([]byte{}, prefix...), link...)
+		for level := 0; level < d.Depth(); level++ {
+			msg = append(msg, d.blocks[level][i]...)
+			i /= 2
+		}
+		return msg, nil
+	}
+}
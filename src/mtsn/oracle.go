@@ -0,0 +1,169 @@
+package mtsn
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RSAOracle decrypts any ciphertext handed to it, but refuses to decrypt
+// the same ciphertext twice until ttl has passed (or ever, if ttl is 0). It
+// is safe to use from several goroutines, and can be served over HTTP.
+//
+// Example usage:
+//
+//    oracle := NewRSAOracle(NewRSA(), time.Minute)
+//    msg, err := oracle.Decrypt(encrypted)
+//
+//    // Or over HTTP
+//    http.Handle("/decrypt", oracle)
+type RSAOracle struct {
+	rsa *RSA
+	ttl time.Duration
+
+	// Now is used to tell the time, and can be swapped out in tests
+	Now func() time.Time
+
+	mutex     sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewRSAOracle sets up an oracle decrypting with rsa, which remembers
+// ciphertexts for ttl.
+func NewRSAOracle(rsa *RSA, ttl time.Duration) *RSAOracle {
+	return &RSAOracle{
+		rsa:  rsa,
+		ttl:  ttl,
+		Now:  time.Now,
+		seen: make(map[string]time.Time),
+	}
+}
+
+// PublicKey returns the public key messages for the oracle are encrypted
+// with.
+func (o *RSAOracle) PublicKey() *RSAPublicKey {
+	return o.rsa.PublicKey()
+}
+
+// Decrypt decrypts encrypted, unless it has already been seen.
+func (o *RSAOracle) Decrypt(encrypted *big.Int) ([]byte, error) {
+	// c and c + n are the same ciphertext, so only track c mod n
+	c := new(big.Int).Mod(encrypted, o.rsa.n)
+	key := string(c.Bytes())
+	now := o.Now()
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.sweep(now)
+	if seenAt, seen := o.seen[key]; seen && (o.ttl == 0 || now.Sub(seenAt) < o.ttl) {
+		return nil, fmt.Errorf("I have seen this ciphertext before!")
+	}
+	o.seen[key] = now
+
+	return o.rsa.Decrypt(c), nil
+}
+
+// sweep drops expired ciphertexts, at most once per ttl so that Decrypt
+// stays cheap. The mutex must be held.
+func (o *RSAOracle) sweep(now time.Time) {
+	if o.ttl == 0 || now.Sub(o.lastSweep) < o.ttl {
+		return
+	}
+	for key, seenAt := range o.seen {
+		if now.Sub(seenAt) >= o.ttl {
+			delete(o.seen, key)
+		}
+	}
+	o.lastSweep = now
+}
+
+// ServeHTTP decrypts the hex encoded ciphertext given in the "c" parameter
+// and writes back the hex encoded message. Ciphertexts that were already
+// seen get a 409 Conflict.
+func (o *RSAOracle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, ok := new(big.Int).SetString(r.FormValue("c"), 16)
+	if !ok {
+		http.Error(w, "Parameter c must be a hex number", http.StatusBadRequest)
+		return
+	}
+
+	msg, err := o.Decrypt(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	fmt.Fprint(w, hex.EncodeToString(msg))
+}
+
+// RSAOracleClient talks to an RSAOracle served over HTTP at URL.
+type RSAOracleClient struct {
+	URL    string
+	Client *http.Client
+}
+
+// Decrypt asks the remote oracle to decrypt encrypted.
+func (c *RSAOracleClient) Decrypt(encrypted *big.Int) ([]byte, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.PostForm(c.URL, url.Values{"c": {encrypted.Text(16)}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Oracle answered %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return hex.DecodeString(string(body))
+}
+
+// BlindingAttack recovers the message behind encrypted from an oracle that
+// will not decrypt encrypted itself. It sends s**e * encrypted instead,
+// and divides the answer by s. If s is nil, a random one is picked.
+func BlindingAttack(decrypt func(*big.Int) ([]byte, error), key *RSAPublicKey, encrypted *big.Int, s *big.Int) ([]byte, error) {
+	var err error
+	if s == nil {
+		s, err = rand.Int(rand.Reader, key.N)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sInv, err := InvMod(new(big.Int).Mod(s, key.N), key.N)
+	if err != nil {
+		return nil, err
+	}
+
+	//C' = ((S**E mod N) C) mod N
+	cPrime := new(big.Int).Exp(s, key.E, key.N)
+	cPrime.Mul(cPrime, encrypted)
+	cPrime.Mod(cPrime, key.N)
+
+	pPrimeBytes, err := decrypt(cPrime)
+	if err != nil {
+		return nil, err
+	}
+
+	//       P'
+	// P = -----  mod N
+	//      S
+	p := new(big.Int).SetBytes(pPrimeBytes)
+	p.Mul(p, sInv)
+	p.Mod(p, key.N)
+	return p.Bytes(), nil
+}
@@ -0,0 +1,253 @@
+package mtsn
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Poly is a polynomial with big.Int coefficients, with the constant term
+// first, i.e. Poly{c, b, a} is a*x**2 + b*x + c.
+type Poly []*big.Int
+
+// NewPoly builds a Poly out of int64 coefficients, constant term first.
+func NewPoly(coefs ...int64) Poly {
+	p := make(Poly, len(coefs))
+	for i, c := range coefs {
+		p[i] = big.NewInt(c)
+	}
+	return p.trim()
+}
+
+// trim removes the zero coefficients at the top of the polynomial.
+func (p Poly) trim() Poly {
+	i := len(p)
+	for i > 0 && p[i-1].Sign() == 0 {
+		i--
+	}
+	return p[0:i]
+}
+
+// Degree of the polynomial, where the zero polynomial has degree -1.
+func (p Poly) Degree() int {
+	return len(p.trim()) - 1
+}
+
+// Eval evaluates the polynomial at x.
+func (p Poly) Eval(x *big.Int) *big.Int {
+	res := new(big.Int)
+	for i := len(p) - 1; i >= 0; i-- {
+		res.Mul(res, x)
+		res.Add(res, p[i])
+	}
+	return res
+}
+
+// Add returns p + q.
+func (p Poly) Add(q Poly) Poly {
+	if len(q) > len(p) {
+		p, q = q, p
+	}
+	res := make(Poly, len(p))
+	for i := range p {
+		res[i] = new(big.Int).Set(p[i])
+		if i < len(q) {
+			res[i].Add(res[i], q[i])
+		}
+	}
+	return res.trim()
+}
+
+// Mul returns p * q.
+func (p Poly) Mul(q Poly) Poly {
+	if len(p) == 0 || len(q) == 0 {
+		return Poly{}
+	}
+	res := make(Poly, len(p)+len(q)-1)
+	for i := range res {
+		res[i] = new(big.Int)
+	}
+	for i, a := range p {
+		for j, b := range q {
+			res[i+j].Add(res[i+j], new(big.Int).Mul(a, b))
+		}
+	}
+	return res.trim()
+}
+
+// Scale returns p multiplied by the constant c.
+func (p Poly) Scale(c *big.Int) Poly {
+	res := make(Poly, len(p))
+	for i, a := range p {
+		res[i] = new(big.Int).Mul(a, c)
+	}
+	return res.trim()
+}
+
+// Mod reduces every coefficient of p mod n.
+func (p Poly) Mod(n *big.Int) Poly {
+	res := make(Poly, len(p))
+	for i, a := range p {
+		res[i] = new(big.Int).Mod(a, n)
+	}
+	return res.trim()
+}
+
+// Pow returns p**e, with coefficients reduced mod n unless n is nil.
+func (p Poly) Pow(e int, n *big.Int) Poly {
+	res := NewPoly(1)
+	for i := 0; i < e; i++ {
+		res = res.Mul(p)
+		if n != nil {
+			res = res.Mod(n)
+		}
+	}
+	return res
+}
+
+// Derivative returns the derivative of p.
+func (p Poly) Derivative() Poly {
+	if len(p) < 2 {
+		return Poly{}
+	}
+	res := make(Poly, len(p)-1)
+	for i := 1; i < len(p); i++ {
+		res[i-1] = new(big.Int).Mul(p[i], big.NewInt(int64(i)))
+	}
+	return res.trim()
+}
+
+// IntegerRoots returns the integer roots of p in [lo, hi], in increasing
+// order.
+func (p Poly) IntegerRoots(lo, hi *big.Int) []*big.Int {
+	var roots []*big.Int
+	for _, x := range p.trim().rootFloors(lo, hi) {
+		if p.Eval(x).Sign() == 0 {
+			roots = append(roots, x)
+		}
+	}
+	return roots
+}
+
+// rootFloors returns floor(r) for every real root r of p in [lo, hi]. p is
+// split into monotone pieces around the roots of its derivative, and each
+// piece is binary searched for a sign change.
+func (p Poly) rootFloors(lo, hi *big.Int) []*big.Int {
+	if p.Degree() < 1 || lo.Cmp(hi) > 0 {
+		return nil
+	}
+
+	points := []*big.Int{lo, hi}
+	for _, c := range p.Derivative().rootFloors(lo, hi) {
+		points = append(points, c)
+		if c.Cmp(hi) < 0 {
+			points = append(points, new(big.Int).Add(c, Big.One))
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Cmp(points[j]) < 0 })
+
+	var floors []*big.Int
+	seen := make(map[string]bool)
+	add := func(x *big.Int) {
+		if !seen[x.String()] {
+			seen[x.String()] = true
+			floors = append(floors, x)
+		}
+	}
+
+	for i, start := range points {
+		startSign := p.Eval(start).Sign()
+		if startSign == 0 {
+			add(start)
+		}
+		if i+1 == len(points) || start.Cmp(points[i+1]) == 0 {
+			continue
+		}
+
+		end := points[i+1]
+		endSign := p.Eval(end).Sign()
+		if startSign == 0 || endSign == 0 || startSign == endSign {
+			continue
+		}
+
+		// Find the last x with the same sign as start, the root is between
+		// that and the next integer.
+		low := new(big.Int).Set(start)
+		high := new(big.Int).Set(end)
+		for new(big.Int).Sub(high, low).Cmp(Big.One) > 0 {
+			mid := new(big.Int).Add(low, high)
+			mid.Rsh(mid, 1)
+			if p.Eval(mid).Sign() == startSign {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+		if p.Eval(high).Sign() == 0 {
+			add(high)
+		} else {
+			add(low)
+		}
+	}
+
+	sort.Slice(floors, func(i, j int) bool { return floors[i].Cmp(floors[j]) < 0 })
+	return floors
+}
+
+// DivMod divides p by q with every coefficient taken mod n, and returns the
+// quotient and the remainder. The leading coefficient of q has to be
+// invertible mod n, otherwise the error returned says so (which, for an RSA
+// modulus, is quite the find).
+func (p Poly) DivMod(q Poly, n *big.Int) (Poly, Poly, error) {
+	q = q.Mod(n)
+	if len(q) == 0 {
+		return nil, nil, fmt.Errorf("Division by the zero polynomial")
+	}
+	leadInv, err := InvMod(q[len(q)-1], n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rem := p.Mod(n)
+	quotient := make(Poly, len(rem))
+	for i := range quotient {
+		quotient[i] = new(big.Int)
+	}
+
+	for len(rem) >= len(q) {
+		shift := len(rem) - len(q)
+		coef := new(big.Int).Mul(rem[len(rem)-1], leadInv)
+		coef.Mod(coef, n)
+		quotient[shift] = coef
+
+		for i, c := range q {
+			sub := new(big.Int).Mul(coef, c)
+			rem[shift+i] = new(big.Int).Sub(rem[shift+i], sub)
+			rem[shift+i].Mod(rem[shift+i], n)
+		}
+		rem = rem.trim()
+	}
+	return quotient.trim(), rem, nil
+}
+
+// GCD returns the monic greatest common divisor of p and q, with every
+// coefficient taken mod n.
+func (p Poly) GCD(q Poly, n *big.Int) (Poly, error) {
+	a, b := p.Mod(n), q.Mod(n)
+	for len(b) > 0 {
+		_, rem, err := a.DivMod(b, n)
+		if err != nil {
+			return nil, err
+		}
+		a, b = b, rem
+	}
+	if len(a) == 0 {
+		return a, nil
+	}
+
+	leadInv, err := InvMod(a[len(a)-1], n)
+	if err != nil {
+		return nil, err
+	}
+	return a.Scale(leadInv).Mod(n), nil
+}
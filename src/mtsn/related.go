@@ -0,0 +1,81 @@
+package mtsn
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// expSigned returns x**y mod n, where y may be negative.
+func expSigned(x, y, n *big.Int) (*big.Int, error) {
+	if y.Sign() >= 0 {
+		return new(big.Int).Exp(x, y, n), nil
+	}
+	inv, err := InvMod(new(big.Int).Mod(x, n), n)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Exp(inv, new(big.Int).Neg(y), n), nil
+}
+
+// CommonModulus recovers a message encrypted to two keys sharing the same
+// modulus. With a*e1 + b*e2 = gcd(e1, e2), c1**a * c2**b = m**gcd(e1, e2),
+// so the message falls right out when the exponents are coprime, and
+// otherwise needs a small integer root.
+func CommonModulus(key1, key2 *RSAPublicKey, c1, c2 *big.Int) (*big.Int, error) {
+	if key1.N.Cmp(key2.N) != 0 {
+		return nil, fmt.Errorf("Keys do not share the same modulus")
+	}
+	n := key1.N
+
+	a, b := new(big.Int), new(big.Int)
+	g := new(big.Int).GCD(a, b, key1.E, key2.E)
+
+	partA, err := expSigned(c1, a, n)
+	if err != nil {
+		return nil, err
+	}
+	partB, err := expSigned(c2, b, n)
+	if err != nil {
+		return nil, err
+	}
+	m := partA.Mul(partA, partB)
+	m.Mod(m, n)
+
+	if g.Cmp(Big.One) == 0 {
+		return m, nil
+	}
+	if !g.IsInt64() {
+		return nil, fmt.Errorf("Exponents share the huge factor %v", g)
+	}
+	root, rem := RootRem(m, int(g.Int64()))
+	if rem.Sign() != 0 {
+		return nil, fmt.Errorf("Exponents share the factor %v, and m**%v wrapped around n", g, g)
+	}
+	return root, nil
+}
+
+// FranklinReiter recovers the message m1 behind c1, given c2 the encryption
+// of m2 = pad.A * m1 + pad.B under the same key. m1 is a root of both
+// x**e - c1 and (pad.A*x + pad.B)**e - c2 mod n, so their gcd is (almost
+// always) x - m1. The polynomials are of degree e, so e has to be small.
+func FranklinReiter(key *RSAPublicKey, c1, c2 *big.Int, pad *LinearPad) (*big.Int, error) {
+	if !key.E.IsInt64() || key.E.Int64() > 1<<16 {
+		return nil, fmt.Errorf("Exponent %v too big for Franklin-Reiter", key.E)
+	}
+	e := int(key.E.Int64())
+	n := key.N
+
+	g1 := NewPoly(0, 1).Pow(e, n).Add(Poly{new(big.Int).Neg(c1)}).Mod(n)
+	g2 := Poly{pad.B, pad.A}.Pow(e, n).Add(Poly{new(big.Int).Neg(c2)}).Mod(n)
+
+	gcd, err := g1.GCD(g2, n)
+	if err != nil {
+		return nil, err
+	}
+	if gcd.Degree() != 1 {
+		return nil, fmt.Errorf("Got a gcd of degree %d instead of 1", gcd.Degree())
+	}
+
+	// gcd is monic, so gcd = x - m1
+	return new(big.Int).Mod(new(big.Int).Neg(gcd[0]), n), nil
+}
@@ -0,0 +1,46 @@
+package mtsn
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Root returns the largest integer x such that x**k <= n, found using
+// Newton's method on integers. n must not be negative and k must be
+// positive.
+func Root(n *big.Int, k int) *big.Int {
+	if n.Sign() < 0 || k < 1 {
+		panic(fmt.Errorf("Cannot take root %d of %v", k, n))
+	}
+	if n.Sign() == 0 || k == 1 {
+		return new(big.Int).Set(n)
+	}
+
+	bigK := big.NewInt(int64(k))
+	bigK1 := big.NewInt(int64(k - 1))
+
+	// Start from a power of two above the root, Newton then walks down
+	// monotonically until it reaches the floor of the root.
+	x := new(big.Int).Lsh(Big.One, uint((n.BitLen()+k-1)/k))
+	for {
+		// y = ((k-1)x + n / x**(k-1)) / k
+		y := new(big.Int).Exp(x, bigK1, nil)
+		y.Div(n, y)
+		y.Add(y, new(big.Int).Mul(bigK1, x))
+		y.Div(y, bigK)
+
+		if y.Cmp(x) >= 0 {
+			return x
+		}
+		x = y
+	}
+}
+
+// RootRem returns the floor of the k-th root of n along with the remainder
+// n - root**k, which is zero exactly when n is a perfect k-th power.
+func RootRem(n *big.Int, k int) (*big.Int, *big.Int) {
+	root := Root(n, k)
+	rem := new(big.Int).Exp(root, big.NewInt(int64(k)), nil)
+	rem.Sub(n, rem)
+	return root, rem
+}
@@ -36,6 +36,8 @@ func InvMod(a, n *big.Int) (*big.Int, error) {
 		return nil, fmt.Errorf("a (%v)  %% n (%v) is not reversable", a, n)
 	}
 
+	// t might still be one of the shared Big values, so hand out a copy
+	t = new(big.Int).Set(t)
 	if t.Cmp(Big.Zero) == -1 {
 		t.Add(t, n)
 	}
@@ -54,6 +56,26 @@ func (r *RSAClient) Encrypt(msg []byte) *big.Int {
 	return c
 }
 
+// RSAPublicKey is a public key with an arbitrary exponent, unlike RSAClient
+// which always uses PublicE.
+type RSAPublicKey struct {
+	N *big.Int
+	E *big.Int
+}
+
+// PublicKey returns the RSAClient as an RSAPublicKey.
+func (r *RSAClient) PublicKey() *RSAPublicKey {
+	return &RSAPublicKey{(*big.Int)(r), PublicE}
+}
+
+// Encrypt a sequence of bytes as a big.Int
+func (k *RSAPublicKey) Encrypt(msg []byte) *big.Int {
+	c := new(big.Int)
+	c.SetBytes(msg)
+	c.Exp(c, k.E, k.N)
+	return c
+}
+
 // RSA is the 'server' (or private) side of an RSA public-private key
 // system. You should keep this object to yourself, but feel free to send
 // the RSAClient to anyone.
@@ -68,6 +90,7 @@ func (r *RSAClient) Encrypt(msg []byte) *big.Int {
 //
 type RSA struct {
 	n *big.Int
+	e *big.Int
 	d *big.Int
 }
 
@@ -75,6 +98,11 @@ func (r *RSA) Client() *RSAClient {
 	return (*RSAClient)(r.n)
 }
 
+// PublicKey returns the public half of the key, including the exponent.
+func (r *RSA) PublicKey() *RSAPublicKey {
+	return &RSAPublicKey{r.n, r.e}
+}
+
 func (r *RSA) Decrypt(encrypted *big.Int) []byte {
 	msg := new(big.Int)
 	msg.Exp(encrypted, r.d, r.n)
@@ -82,17 +110,23 @@ func (r *RSA) Decrypt(encrypted *big.Int) []byte {
 }
 
 func NewRSA() *RSA {
+	return NewRSAWithExponent(RSA_BITS, PublicE)
+}
+
+// NewRSAWithExponent generates an RSA key with primes of the given size in
+// bits and e as the public exponent.
+func NewRSAWithExponent(bits int, e *big.Int) *RSA {
 	rsa := new(RSA)
 
 	var p, q, et *big.Int
 	var err error
 
 	for {
-		p, err = rand.Prime(rand.Reader, RSA_BITS)
+		p, err = rand.Prime(rand.Reader, bits)
 		if err != nil {
 			panic(err)
 		}
-		q, err = rand.Prime(rand.Reader, RSA_BITS)
+		q, err = rand.Prime(rand.Reader, bits)
 		if err != nil {
 			panic(err)
 		}
@@ -101,21 +135,38 @@ func NewRSA() *RSA {
 		q1 := new(big.Int).Sub(q, Big.One)
 		et = new(big.Int).Mul(p1, q1)
 
-		rem := new(big.Int).Mod(et, Big.Three)
-		if rem.Cmp(Big.Zero) != 0 {
-			// If et % 3 != 0, then gcd(et, 3) == 1, so it is usable. Otherwise,
-			// try with a new p and q
+		gcd := new(big.Int).GCD(nil, nil, et, e)
+		if gcd.Cmp(Big.One) == 0 {
+			// e has an inverse mod et, so it is usable. Otherwise, try with a
+			// new p and q
 			break
 		}
 	}
 
 	rsa.n = new(big.Int)
 	rsa.n.Mul(p, q)
+	rsa.e = e
 
-	rsa.d, err = InvMod(PublicE, et)
+	rsa.d, err = InvMod(e, et)
 	if err != nil {
 		panic(err)
 	}
 
 	return rsa
 }
+
+// NewRSAFromPrimes builds an RSA key out of the given primes and public
+// exponent, which is handy to set up (or recover) keys that NewRSA would
+// never produce.
+func NewRSAFromPrimes(p, q, e *big.Int) (*RSA, error) {
+	p1 := new(big.Int).Sub(p, Big.One)
+	q1 := new(big.Int).Sub(q, Big.One)
+	et := new(big.Int).Mul(p1, q1)
+
+	d, err := InvMod(e, et)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RSA{new(big.Int).Mul(p, q), e, d}, nil
+}
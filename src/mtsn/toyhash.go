@@ -0,0 +1,117 @@
+package mtsn
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+	"hashhacks"
+)
+
+// Size of the blocks a ToyHash eats, which is the AES block size
+const TOY_BLOCK_SIZE = aes.BlockSize
+
+// ToyHash is a Merkle–Damgård hash with a tiny state, so that attacks on its
+// structure run in seconds. The compression function encrypts the message
+// block with AES, keyed by the state, and keeps the bottom Bits bits of the
+// result. Messages are padded like MD4 and SHA-1 are, with the length at the
+// end.
+type ToyHash struct {
+	Bits uint
+	IV   uint64
+}
+
+// NewToyHash builds a ToyHash with a state of bits bits, between 1 and 64.
+func NewToyHash(bits uint) *ToyHash {
+	if bits < 1 || bits > 64 {
+		panic(fmt.Errorf("ToyHash state must be between 1 and 64 bits, not %d", bits))
+	}
+	h := &ToyHash{Bits: bits}
+	h.IV = 0x0123456789abcdef & h.mask()
+	return h
+}
+
+func (h *ToyHash) mask() uint64 {
+	return ^uint64(0) >> (64 - h.Bits)
+}
+
+// Compress runs the compression function on a single block.
+func (h *ToyHash) Compress(state uint64, block []byte) uint64 {
+	key := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(key[aes.BlockSize-8:], state)
+	cipher, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+
+	output := make([]byte, aes.BlockSize)
+	cipher.Encrypt(output, block)
+	return binary.BigEndian.Uint64(output[aes.BlockSize-8:]) & h.mask()
+}
+
+// Iterate compresses every block of blocks in turn, starting from state.
+// blocks must be a whole number of blocks long.
+func (h *ToyHash) Iterate(state uint64, blocks []byte) uint64 {
+	if len(blocks)%TOY_BLOCK_SIZE != 0 {
+		panic(fmt.Errorf("Cannot iterate over %d bytes, not a whole number of blocks", len(blocks)))
+	}
+	for i := 0; i < len(blocks); i += TOY_BLOCK_SIZE {
+		state = h.Compress(state, blocks[i:i+TOY_BLOCK_SIZE])
+	}
+	return state
+}
+
+// Padding returns what gets appended to a message of length bytes.
+func (h *ToyHash) Padding(length int) []byte {
+	return hashhacks.Padding(uint64(length), TOY_BLOCK_SIZE, 8, false)
+}
+
+// Finish pads a message of length bytes which left the hash in state, and
+// returns the digest.
+func (h *ToyHash) Finish(state uint64, length int) uint64 {
+	return h.Iterate(state, h.Padding(length))
+}
+
+// Sum returns the digest of msg.
+func (h *ToyHash) Sum(msg []byte) uint64 {
+	full := len(msg) - len(msg)%TOY_BLOCK_SIZE
+	state := h.Iterate(h.IV, msg[:full])
+
+	tail := append(append([]byte{}, msg[full:]...), h.Padding(len(msg))...)
+	return h.Iterate(state, tail)
+}
+
+// CollideFrom looks for a block x and a block y such that compressing x from
+// a lands on the same state as compressing y from b. It is a plain birthday
+// search, so it takes about 2**(Bits/2) compressions. If a and b are the
+// same, x and y are guaranteed to be different.
+func (h *ToyHash) CollideFrom(a uint64, b uint64) ([]byte, []byte, uint64) {
+	fromA := make(map[uint64][]byte)
+	fromB := make(map[uint64][]byte)
+
+	for {
+		x := GenerateRandomKey()
+		stateA := h.Compress(a, x)
+		if y, found := fromB[stateA]; found && (a != b || !bytes.Equal(x, y)) {
+			return x, y, stateA
+		}
+		if a == b {
+			fromB[stateA] = x
+			continue
+		}
+		fromA[stateA] = x
+
+		y := GenerateRandomKey()
+		stateB := h.Compress(b, y)
+		if x, found := fromA[stateB]; found {
+			return x, y, stateB
+		}
+		fromB[stateB] = y
+	}
+}
+
+// Collision finds two different blocks which compress to the same state
+// starting from state, and returns them along with that state.
+func (h *ToyHash) Collision(state uint64) ([]byte, []byte, uint64) {
+	return h.CollideFrom(state, state)
+}
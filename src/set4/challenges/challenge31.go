@@ -3,19 +3,27 @@ package set4
 import (
 	"fmt"
 	"mtsn"
-	"crypto/subtle"
 	"sha1hacks"
 	"time"
 )
 
+// HmacVerifier checks SHA-1 HMACs with a secret key. Its insecure comparison
+// sleeps for delay after every matching byte.
 type HmacVerifier struct {
 	key []byte
-	delay int
+	delay time.Duration
+}
+
+// NewHmacVerifier instantiates a HmacVerifier with the given key and per byte
+// delay.
+func NewHmacVerifier(key []byte, delay time.Duration) *HmacVerifier {
+	return &HmacVerifier{key, delay}
 }
 
 func (h *HmacVerifier) compare(text []byte, digest []byte) bool {
-	realDigest := mtsn.Sha1HMAC(h.key, text)
-	return (subtle.ConstantTimeCompare(digest, realDigest) == 1)
+	mac := mtsn.NewHMAC(sha1hacks.New, h.key)
+	mac.Write(text)
+	return mac.Verify(digest)
 }
 
 func (h *HmacVerifier) insecureCompare(text []byte, digest []byte) bool {
@@ -24,7 +32,7 @@ func (h *HmacVerifier) insecureCompare(text []byte, digest []byte) bool {
 		if realDigest[i] != digest[i] {
 			return false
 		}
-		time.Sleep(time.Millisecond * time.Duration(h.delay))
+		time.Sleep(h.delay)
 	}
 	return true
 }
@@ -55,7 +63,7 @@ func findByte(verifier *HmacVerifier, text []byte, pos int, prev []byte) byte {
 
 func Challenge31() {
 	key := mtsn.GenerateRandomKey()
-	verifier := &HmacVerifier{key, 50}
+	verifier := NewHmacVerifier(key, 50 * time.Millisecond)
 	badFile := []byte("I am a bad file which will ruin your day")
 
 	fakeSignature := make([]byte, sha1hacks.Size)
@@ -0,0 +1,79 @@
+package set4
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sha1hacks"
+	"timing"
+)
+
+// HmacServer serves /test?file=...&signature=..., answering 200 OK when
+// signature is the hex encoded HMAC of file and 500 otherwise. It checks
+// the signature with insecureCompare, so it leaks how much of it was right
+// through the verifier's per byte delay.
+//
+// Example usage:
+//
+//    verifier := NewHmacVerifier(key, 5 * time.Millisecond)
+//    server := httptest.NewServer(NewHmacServer(verifier))
+//    defer server.Close()
+//    client := &HmacClient{URL: server.URL}
+type HmacServer struct {
+	verifier *HmacVerifier
+}
+
+// NewHmacServer builds an http.Handler checking signatures with verifier.
+func NewHmacServer(verifier *HmacVerifier) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/test", &HmacServer{verifier})
+	return mux
+}
+
+func (h *HmacServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	signature, err := hex.DecodeString(r.FormValue("signature"))
+	if err != nil || len(signature) != sha1hacks.Size {
+		http.Error(w, "Signature must be a hex encoded SHA-1 HMAC", http.StatusBadRequest)
+		return
+	}
+
+	if !h.verifier.insecureCompare([]byte(r.FormValue("file")), signature) {
+		http.Error(w, "Bad signature", http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte("OK"))
+}
+
+// HmacClient talks to an HmacServer listening at URL.
+type HmacClient struct {
+	URL    string
+	Client *http.Client
+}
+
+// TestURL builds the URL checking signature for file.
+func (c *HmacClient) TestURL(file []byte, signature []byte) string {
+	values := url.Values{"file": {string(file)}, "signature": {hex.EncodeToString(signature)}}
+	return c.URL + "/test?" + values.Encode()
+}
+
+// Oracle times the server checking signatures for file.
+func (c *HmacClient) Oracle(file []byte) timing.Oracle {
+	return timing.HTTPOracle(c.Client, func(signature []byte) string {
+		return c.TestURL(file, signature)
+	})
+}
+
+// Check asks the server whether signature is right for file.
+func (c *HmacClient) Check(file []byte, signature []byte) bool {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(c.TestURL(file, signature))
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
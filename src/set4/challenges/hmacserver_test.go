@@ -0,0 +1,73 @@
+package set4
+
+import (
+	"mtsn"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHmacServer(t *testing.T) {
+	key := []byte("YELLOW SUBMARINE")
+	file := []byte("some file")
+	signature := mtsn.Sha1HMAC(key, file)
+	wrong := append([]byte{}, signature...)
+	wrong[len(wrong)-1] ^= 1
+
+	server := httptest.NewServer(NewHmacServer(NewHmacVerifier(key, 0)))
+	defer server.Close()
+	client := &HmacClient{URL: server.URL}
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{"right signature", client.TestURL(file, signature), http.StatusOK},
+		{"wrong signature", client.TestURL(file, wrong), http.StatusInternalServerError},
+		{"other file", client.TestURL([]byte("other file"), signature), http.StatusInternalServerError},
+		{"bad hex", server.URL + "/test?file=x&signature=zz", http.StatusBadRequest},
+		{"short signature", client.TestURL(file, signature[:4]), http.StatusBadRequest},
+		{"no signature", server.URL + "/test?file=x", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		resp, err := http.Get(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: got status %d, expected %d", test.name, resp.StatusCode, test.status)
+		}
+	}
+
+	if !client.Check(file, signature) {
+		t.Errorf("Client rejected the right signature")
+	}
+	if client.Check(file, wrong) {
+		t.Errorf("Client accepted a wrong signature")
+	}
+	down := &HmacClient{URL: "http://127.0.0.1:1"}
+	if down.Check(file, signature) {
+		t.Errorf("Client accepted a signature without reaching the server")
+	}
+}
+
+func TestHTTPSignatureBreakerCheck(t *testing.T) {
+	key := []byte("YELLOW SUBMARINE")
+	file := []byte("some file")
+	signature := mtsn.Sha1HMAC(key, file)
+
+	// The brute forced tail is checked in process, so it works (and stays
+	// fast) without the server
+	down := &HmacClient{URL: "http://127.0.0.1:1"}
+	breaker := NewHTTPSignatureBreaker(down, NewHmacVerifier(key, time.Hour), file)
+	if !breaker.check(signature) {
+		t.Errorf("Breaker rejected the right signature")
+	}
+	signature[0] ^= 1
+	if breaker.check(signature) {
+		t.Errorf("Breaker accepted a wrong signature")
+	}
+}
@@ -5,8 +5,10 @@ import (
 )
 
 func Challenge33() {
-	a := NewDiffieHellman(DFConstants.P, DFConstants.G)
-	b := NewDiffieHellman(DFConstants.P, DFConstants.G)
+	a, err := NewDiffieHellman(DFConstants.P, DFConstants.G)
+	if err != nil {panic(err)}
+	b, err := NewDiffieHellman(DFConstants.P, DFConstants.G)
+	if err != nil {panic(err)}
 
 	key_a := a.SessionKey(b.MyPublic)
 	key_b := b.SessionKey(a.MyPublic)
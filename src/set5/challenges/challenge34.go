@@ -52,6 +52,9 @@ type NormalParty struct {
 	df *DiffieHellman
 	otherPublicKey *big.Int
 	msg []byte
+
+	// validate makes the party refuse unknown groups and bad public values
+	validate bool
 }
 
 func (p *NormalParty) InitDF() *DFInit {
@@ -66,7 +69,21 @@ func (p *NormalParty) RespondToInit(init *DFInit) (*big.Int, error) {
 	if (p.df != nil) {
 		return nil, fmt.Errorf("RespondToInit: Already have DF initialized")
 	}
-	p.df = NewDiffieHellman(init.P, init.G)
+
+	var err error
+	if p.validate {
+		var group *DHGroup
+		group, err = FindDHGroup(init.P, init.G)
+		if (err != nil) {return nil, fmt.Errorf("RespondToInit: %v", err)}
+		p.df, err = NewDiffieHellmanGroup(group)
+		if (err != nil) {return nil, err}
+
+		_, err = p.df.ValidSessionKey(init.Public)
+		if (err != nil) {return nil, fmt.Errorf("RespondToInit: %v", err)}
+	} else {
+		p.df, err = NewDiffieHellman(init.P, init.G)
+		if (err != nil) {return nil, err}
+	}
 	p.otherPublicKey = init.Public
 	return p.df.MyPublic, nil
 }
@@ -75,6 +92,13 @@ func (p *NormalParty) SendMsg(otherPublicKey *big.Int) ([]byte, error) {
 	if (p.msg == nil) {
 		return nil, fmt.Errorf("SendMsg: Cannot SendMsg if not initiating party.")
 	}
+	if (p.df == nil) {
+		return nil, fmt.Errorf("SendMsg: Cannot SendMsg without DF initialized")
+	}
+	if p.validate {
+		_, err := p.df.ValidSessionKey(otherPublicKey)
+		if (err != nil) {return nil, fmt.Errorf("SendMsg: %v", err)}
+	}
 	p.otherPublicKey = otherPublicKey
 
 	iv := mtsn.GenerateRandomKey()
@@ -88,7 +112,7 @@ func (p *NormalParty) AcknowlegeMsg(payload []byte) ([]byte, error) {
 	if len(payload) < 16 {
 		return nil, fmt.Errorf("AcknowlegeMsg: Cannot acknowlege message without 16 byte iv at the end")
 	}
-	if p.otherPublicKey == nil {
+	if p.df == nil || p.otherPublicKey == nil {
 		return nil, fmt.Errorf("AcknowlegeMsg: Must have exchanged public keys beforehand")
 	}
 
@@ -107,13 +131,13 @@ func (p *NormalParty) AcknowlegeMsg(payload []byte) ([]byte, error) {
 
 func (p *NormalParty) AcknowlegeResponse(payload []byte) error {
 	if len(payload) < 16 {
-		fmt.Errorf("AcknowlegeResponse: Cannot acknowlege message without 16 byte iv at the end")
+		return fmt.Errorf("AcknowlegeResponse: Cannot acknowlege message without 16 byte iv at the end")
 	}
-	if p.otherPublicKey == nil {
-		fmt.Errorf("AcknowlegeResponse: Must have exchanged public keys beforehand")
+	if p.df == nil || p.otherPublicKey == nil {
+		return fmt.Errorf("AcknowlegeResponse: Must have exchanged public keys beforehand")
 	}
 	if p.msg == nil {
-		fmt.Errorf("AcknowlegeResponse: Must be the initial party with the message")
+		return fmt.Errorf("AcknowlegeResponse: Must be the initial party with the message")
 	}
 
 	encrypted := payload[0:len(payload)-16]
@@ -148,93 +172,79 @@ func ExchangeMessage(a Party, b Party) error {
  * Code for the Man-In-The-Middle hack
  */
 
-// Mitm is a Man-In-The-Middle for the ExhangeMessage protocol
-type Mitm struct {
-	partyA Party
-	partyB Party
-	aInit *DFInit
-	msg []byte
-}
-
-func NewMITM(partyA Party, partyB Party) *Mitm {
-	mitm := new(Mitm)
-	mitm.partyA = partyA
-	mitm.partyB = partyB
-	return mitm
-}
-
-func (m *Mitm) InitDF() *DFInit {
-	m.aInit = m.partyA.InitDF()
-	return m.aInit
-}
-
-func (m *Mitm) RespondToInit(init *DFInit) (*big.Int, error) {
-	hackedInit := new(DFInit)
-	hackedInit.P = m.aInit.P
-	hackedInit.G = m.aInit.G
-	hackedInit.Public = m.aInit.P
-
-	return m.partyB.RespondToInit(hackedInit)
-}
-
-func (m *Mitm) SendMsg(otherPublicKey *big.Int) ([]byte, error) {
-	payload, err := m.partyA.SendMsg(m.aInit.P)
-	if (err != nil) {panic(err)}
-
-	encrypted := payload[0:len(payload)-16]
-	iv := payload[len(payload)-16:len(payload)]
+// NewMITM is a Man-In-The-Middle for the ExhangeMessage protocol, which hands
+// both parties p as the other's public value.
+func NewMITM(partyA Party, partyB Party) *Interceptor {
+	mitm := NewInterceptor(partyA, partyB)
+	mitm.OnInit(func(s *Session, init *DFInit) (*DFInit, error) {
+		return &DFInit{init.P, init.G, init.P}, nil
+	})
+	mitm.OnInitResponse(func(s *Session, public *big.Int) (*big.Int, error) {
+		return s.Init.P, nil
+	})
 
 	// p**n mod p == 0, therefore the key is the empty []byte (as per
 	// big.Int.Bytes())
-	key := sha1hacks.Sum([]byte{})
-	var decrypted []byte
-
-	decrypted, err = mtsn.DecryptAesCbc(key[0:16], iv, encrypted)
-	if (err != nil) {panic(err)}
-	
-	m.msg, err = mtsn.StripPkcs7(decrypted)
-	if (err != nil) {panic(err)}
-
-	return payload,	nil
-}
-
-func (m *Mitm) AcknowlegeMsg(payload []byte) ([]byte, error) {
-	return m.partyB.AcknowlegeMsg(payload)
+	mitm.OnMsg(DecryptWith(mtsn.Big.Zero))
+	return mitm
 }
 
-func (m *Mitm) AcknowlegeResponse(payload []byte) error {
-	return m.partyA.AcknowlegeResponse(payload)
+// NewNormalParty sets up a party for ExchangeMessage. The party starting
+// the exchange gets a msg to send and the group to use, the other one gets
+// neither. A validating party checks the parameters and public values it
+// is handed.
+func NewNormalParty(msg []byte, group *DHGroup, validate bool) *NormalParty {
+	party := &NormalParty{msg: msg, validate: validate}
+	if group != nil {
+		df, err := NewDiffieHellmanGroup(group)
+		if err != nil {panic(err)}
+		party.df = df
+	}
+	return party
 }
 
 func Challenge34() {
-	partyA := new(NormalParty)
-	partyA.df = NewDiffieHellman(DFConstants.P, DFConstants.G)
+	group, err := LookupDHGroup("modp1536")
+	if err != nil {panic(err)}
 	hiddenMsg := []byte("Hello B")
-	partyA.msg = hiddenMsg
-
-	partyB := new(NormalParty)
+	partyA := NewNormalParty(hiddenMsg, group, false)
+	partyB := NewNormalParty(nil, nil, false)
 
 	// Normal message passing
-	err := ExchangeMessage(partyA, partyB)
+	err = ExchangeMessage(partyA, partyB)
 	if err != nil {panic(err)}
 
 	// Now with a MITM
 
 	// Need to re-initialize the parties
-	partyA = new(NormalParty)
-	partyA.df = NewDiffieHellman(DFConstants.P, DFConstants.G)
-	partyA.msg = hiddenMsg
-
-	partyB = new(NormalParty)
+	partyA = NewNormalParty(hiddenMsg, group, false)
+	partyB = NewNormalParty(nil, nil, false)
 
 	mitm := NewMITM(partyA, partyB)
 	err = ExchangeMessage(mitm, mitm)
 	if err != nil {panic(err)}
 
+	// Parties validating public values spot the MITM
+	validA := NewNormalParty(hiddenMsg, group, true)
+	validB := NewNormalParty(nil, nil, true)
+	validMitm := NewMITM(validA, validB)
+	refused := ExchangeMessage(validMitm, validMitm) != nil
+
+	// The same again with the parties talking over TCP, and the MITM as a
+	// proxy in between
+	partyA = NewNormalParty(hiddenMsg, group, false)
+	partyB = NewNormalParty(nil, nil, false)
+	proxied, err := ExchangeThroughProxy(partyA, partyB, func(a Party, b Party) Party {
+		return NewMITM(a, b)
+	})
+	if err != nil {panic(err)}
+
 	fmt.Printf(
-		"Challenge 34: for hidden msg %q, got %q. Match? %v\n",
+		"Challenge 34: for hidden msg %q, got %q. Match? %v Over TCP? %v Validating parties refused? %v\n",
 		hiddenMsg,
-		mitm.msg,
-		bytes.Compare(hiddenMsg, mitm.msg) == 0,	
+		mitm.Session.Plaintext,
+		bytes.Compare(hiddenMsg, mitm.Session.Plaintext) == 0,
+		bytes.Compare(hiddenMsg, proxied.(*Interceptor).Session.Plaintext) == 0,
+		refused,
 	)
 }
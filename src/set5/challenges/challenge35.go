@@ -4,161 +4,81 @@ import (
 	"bytes"
 	"fmt"
 	"math/big"
-	"sha1hacks"
 	"mtsn"
 )
 
-// HackGMitm is a Man-In-The-Middle which let's you play around with the value
-// for G within the ExhangeMessage protocol
-type HackGMitm struct {
-	Mitm
-	g *big.Int
-
-	partyAPublic *big.Int
-	fakeSessionKey *big.Int
-}
-
-func NewHackGMitm(partyA Party, partyB Party, g *big.Int) *HackGMitm {
-	mitm := new(HackGMitm)
-	mitm.partyA = partyA
-	mitm.partyB = partyB
-	mitm.g = g
+// NewHackGMitm is a Man-In-The-Middle which let's you play around with the
+// value for G within the ExhangeMessage protocol. partyB gets g, and
+// partyAPublic as the public value of partyA. The message gets decrypted
+// with the first of sessionKeys that works.
+func NewHackGMitm(partyA Party, partyB Party, g *big.Int, partyAPublic *big.Int, sessionKeys ...*big.Int) *Interceptor {
+	mitm := NewInterceptor(partyA, partyB)
+	mitm.OnInit(func(s *Session, init *DFInit) (*DFInit, error) {
+		return &DFInit{init.P, g, partyAPublic}, nil
+	})
+	mitm.OnMsg(DecryptWith(sessionKeys...))
 	return mitm
 }
 
-func (m *HackGMitm) RespondToInit(init *DFInit) (*big.Int, error) {
-	hackedInit := new(DFInit)
-	hackedInit.P = m.aInit.P
-	hackedInit.G = m.g
-
-	// We set the public key to G such that partyB will also have the same
-	// session key
-	hackedInit.Public = m.partyAPublic
-
-	return m.partyB.RespondToInit(hackedInit)
-}
-
-func (m *HackGMitm) SendMsg(otherPublicKey *big.Int) ([]byte, error) {
-	payload, err := m.partyA.SendMsg(otherPublicKey)
-	if (err != nil) {panic(err)}
-
-	encrypted := payload[0:len(payload)-16]
-	iv := payload[len(payload)-16:len(payload)]
-
-	key := sha1hacks.Sum(m.fakeSessionKey.Bytes())
-	var decrypted []byte
-
-	decrypted, err = mtsn.DecryptAesCbc(key[0:16], iv, encrypted)
-	if (err != nil) {panic(err)}
-	
-	m.msg, err = mtsn.StripPkcs7(decrypted)
-	if (err != nil) {panic(err)}
-
-	return payload,	nil
-}
-
 /**
  * Specific Mitm hack for when g = p - 1
  */
-type HackGMitmEven struct {
-	HackGMitm
-}
+func NewHackGMitmEven(partyA Party, partyB Party) *Interceptor {
+	pMinusOne := new(big.Int).Sub(DFConstants.P, mtsn.Big.One)
 
-func NewHackGMitmEven(partyA Party, partyB Party) *HackGMitmEven {
-	mitm := new(HackGMitmEven)
-	mitm.partyA = partyA
-	mitm.partyB = partyB
-	mitm.g = new(big.Int)
-	mitm.g.Sub(DFConstants.P, mtsn.Big.One)
-	return mitm
-}
-
-func (m *HackGMitmEven) SendMsg(otherPublicKey *big.Int) ([]byte, error) {
-	payload, err := m.partyA.SendMsg(otherPublicKey)
-	if (err != nil) {panic(err)}
-
-	encrypted := payload[0:len(payload)-16]
-	iv := payload[len(payload)-16:len(payload)]
-
-	// Assume a*b is even
-	var decrypted []byte
-	evenKey := sha1hacks.Sum(mtsn.Big.One.Bytes())
-	
-	decrypted, err = mtsn.DecryptAesCbc(evenKey[0:16], iv, encrypted)
-	if (err != nil) {panic(err)}
-	
-	decrypted, err = mtsn.StripPkcs7(decrypted)
-	if (err != nil) {
-		// The even key did not work, let's try the odd key
-		oddKey := sha1hacks.Sum(m.g.Bytes())
-		decrypted, err = mtsn.DecryptAesCbc(oddKey[0:16], iv, encrypted)
-		if (err != nil) {panic(err)}
-	
-		decrypted, err = mtsn.StripPkcs7(decrypted)
-		if (err != nil) {panic(err)}
-	}
-	m.msg = decrypted
-
-	return payload,	nil
+	// Assume a*b is even, and if not try the odd key
+	return NewHackGMitm(partyA, partyB, pMinusOne, pMinusOne, mtsn.Big.One, pMinusOne)
 }
 
 func Challenge35() {
 	var matches [3]bool
-	var err error
 	var partyA, partyB *NormalParty
-	var mitm *HackGMitm
+	var mitm *Interceptor
 	hiddenMsg := []byte("Hello B")
 
+	group, err := LookupDHGroup("modp1536")
+	if err != nil {panic(err)}
+
 	// Init the parties
-	partyA = new(NormalParty)
-	partyA.df = NewDiffieHellman(DFConstants.P, DFConstants.G)
-	partyA.msg = hiddenMsg
-	partyB = new(NormalParty)
+	partyA = NewNormalParty(hiddenMsg, group, false)
+	partyB = NewNormalParty(nil, nil, false)
 
 	// Try with g == 1
-	mitm = NewHackGMitm(partyA, partyB, mtsn.Big.One)
 
 	// A = g^a mod p
 	// B = 1^b mod p = 1
 	// Sa = 1^a mod p = 1
 	// If we give partyB 1 for the public key for partyA, they will share 1 as
 	// the same session key
-	mitm.partyAPublic = mtsn.Big.One
-	mitm.fakeSessionKey = mtsn.Big.One
+	mitm = NewHackGMitm(partyA, partyB, mtsn.Big.One, mtsn.Big.One, mtsn.Big.One)
 
 	// Note that I'm too lazy to implement the step two ACK in the challenge
 	// description as the protocol defined in challenge 34 does the same
 	// thing.
 	err = ExchangeMessage(mitm, mitm)
 	if err != nil {panic(err)}
-	matches[0] = bytes.Compare(hiddenMsg, mitm.msg) == 0
+	matches[0] = bytes.Compare(hiddenMsg, mitm.Session.Plaintext) == 0
 
 	// Re-init the parties
-	partyA = new(NormalParty)
-	partyA.df = NewDiffieHellman(DFConstants.P, DFConstants.G)
-	partyA.msg = hiddenMsg
-	partyB = new(NormalParty)
+	partyA = NewNormalParty(hiddenMsg, group, false)
+	partyB = NewNormalParty(nil, nil, false)
 
 	// Try with g == p
-	mitm = NewHackGMitm(partyA, partyB, DFConstants.P)
 
 	// A = g^a mod p
 	// B = 0^b mod p = 0
 	// Sa = 0^a mod p = 0
 	// If we give partyB 0 for the public key for partyA, they will share 0 as
 	// the same session key
-	mitm.partyAPublic = mtsn.Big.Zero
-	mitm.fakeSessionKey = mtsn.Big.Zero
+	mitm = NewHackGMitm(partyA, partyB, DFConstants.P, mtsn.Big.Zero, mtsn.Big.Zero)
 
 	err = ExchangeMessage(mitm, mitm)
 	if err != nil {panic(err)}
-	matches[1] = bytes.Compare(hiddenMsg, mitm.msg) == 0
+	matches[1] = bytes.Compare(hiddenMsg, mitm.Session.Plaintext) == 0
 
 	// Re-init the parties
-	partyA = new(NormalParty)
-	partyA.df = NewDiffieHellman(DFConstants.P, DFConstants.G)
-	partyA.msg = hiddenMsg
-	partyB = new(NormalParty)
+	partyA = NewNormalParty(hiddenMsg, group, false)
+	partyB = NewNormalParty(nil, nil, false)
 
 	// Try with g == p - 1
 	
@@ -173,7 +93,13 @@ func Challenge35() {
 
 	// We don't care if the exchange succeds.
 	_ = ExchangeMessage(mitmEven, mitmEven)
-	matches[2] = bytes.Compare(hiddenMsg, mitm.msg) == 0
+	matches[2] = bytes.Compare(hiddenMsg, mitmEven.Session.Plaintext) == 0
+
+	// A validating partyB will not take a g from outside a known group
+	partyA = NewNormalParty(hiddenMsg, group, true)
+	partyB = NewNormalParty(nil, nil, true)
+	mitm = NewHackGMitm(partyA, partyB, mtsn.Big.One, mtsn.Big.One, mtsn.Big.One)
+	refused := ExchangeMessage(mitm, mitm) != nil
 
-	fmt.Printf("Challenge 35: Found messages? %v\n", matches)
+	fmt.Printf("Challenge 35: Found messages? %v Validating party refused? %v\n", matches, refused)
 }
\ No newline at end of file
@@ -6,7 +6,7 @@ import (
 	"math/big"
 	"crypto/sha256"
 	"crypto/rand"
-	"crypto/hmac"
+	"srp"
 )
 
 // Constants used in a Secure Remote Password exchange
@@ -42,9 +42,7 @@ func HashTwoInts(a,b  *big.Int) HashSha256 {
 }
 
 func MakeHmac(secret, value []byte) HashSha256 {
-	mac := hmac.New(sha256.New, secret)
-	mac.Write(value)
-	return mac.Sum(nil)
+	return mtsn.HMACSum(sha256.New, secret, value)
 }
 
 type SRPClientIntf interface {
@@ -88,6 +86,10 @@ type SRPServer struct {
 	pubB *big.Int
 	k []byte
 
+	// validate makes the server refuse public values that are 0 mod N or
+	// out of range
+	validate bool
+
 	A *big.Int
 }
 
@@ -108,6 +110,23 @@ func NewSRPServer(password []byte) *SRPServer {
 	return res
 }
 
+// NewValidatingSRPServer is NewSRPServer for a server which checks the
+// client's public value before letting it in.
+func NewValidatingSRPServer(password []byte) *SRPServer {
+	res := NewSRPServer(password)
+	res.validate = true
+	return res
+}
+
+// ValidA checks the client's public value: anything 0 mod N would let the
+// client in without the password.
+func (s *SRPServer) ValidA() error {
+	if s.A.Sign() <= 0 || s.A.Cmp(DFConstants.P) >= 0 {
+		return fmt.Errorf("Public value is out of range")
+	}
+	return nil
+}
+
 func (c *SRPClient) Step1(server *SRPServer) {
 	server.email = c.email
 
@@ -174,7 +193,12 @@ func (c *SRPClient) Step4() []byte {
 }
 
 func (s *SRPServer) Step5(digest []byte) bool {
-	return hmac.Equal(MakeHmac(s.k, s.salt), digest)
+	if s.validate && s.ValidA() != nil {
+		return false
+	}
+	mac := mtsn.NewHMAC(sha256.New, s.k)
+	mac.Write(s.salt)
+	return mac.Verify(digest)
 }
 
 func SRPExchange(server *SRPServer, client SRPClientIntf) bool {
@@ -191,4 +215,15 @@ func Challenge36() {
 	client := NewSRPClient([]byte("abe@example.com"), []byte("password"))
 	server := NewSRPServer([]byte("password"))
 	fmt.Printf("Challenge 36: Successfully exchanged? %v\n", SRPExchange(server, client))
+
+	// The same exchange done as RFC 5054 asks
+	config := srp.SHA256Config()
+	salt, v, err := config.NewVerifier([]byte("abe@example.com"), []byte("password"))
+	if (err != nil) {panic(err)}
+	rfcClient, err := srp.NewClient(config, []byte("abe@example.com"), []byte("password"))
+	if (err != nil) {panic(err)}
+	rfcServer, err := srp.NewServer(config, []byte("abe@example.com"), salt, v)
+	if (err != nil) {panic(err)}
+	_, err = srp.Exchange(rfcClient, rfcServer)
+	fmt.Printf("Challenge 36: RFC 5054 exchange? %v\n", err == nil)
 }
@@ -5,6 +5,7 @@ import (
 	"math/big"
 	"crypto/rand"
 	"mtsn"
+	"srp"
 )
 
 type SRPHackedClient struct {
@@ -66,4 +67,18 @@ func Challenge37() {
 	results[2] = SRPExchange(server, client)
 
 	fmt.Printf("Challenge 37: Logged in each time? %v\n", results)
+
+	// The whole table of attacks, against the plain and validating servers
+	// and the RFC 5054 one
+	subjects := []*srp.Subject{
+		NewSRPServerSubject([]byte("abe@example.com"), []byte("password"), false),
+		NewSRPServerSubject([]byte("abe@example.com"), []byte("password"), true),
+	}
+	rfcSubject, err := srp.NewServerSubject(srp.SHA256Config(), []byte("abe@example.com"), []byte("password"))
+	if (err != nil) {panic(err)}
+	subjects = append(subjects, rfcSubject)
+
+	for _, subject := range subjects {
+		fmt.Print(srp.Report("Challenge 37: " + subject.Name, srp.RunAttacks(subject)))
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"math/big"
 	"crypto/rand"
 	"bytes"
+	"context"
 )
 
 // We use 10 passwords here for sake of speed, but this should really be a
@@ -23,6 +24,9 @@ var passwords [][]byte = [][]byte{
     []byte("football"),
 }
 
+// Mangling used to crack captured transcripts
+var crackRules = CrackConfig{Rules: []ManglingRule{CaseRule, LeetRule, SuffixDigitsRule(2)}}
+
 type SimpleSRPServerIntf interface {
     Step1([]byte, *big.Int)
     Step2() ([]byte, *big.Int, *big.Int)
@@ -137,29 +141,26 @@ func (s *MitmSimpleSRPServer) Step3 (clientHmac []byte) bool {
 	return false
 }
 
+// Transcript is what the MITM captured of the exchange.
+func (s *MitmSimpleSRPServer) Transcript() *SRPTranscript {
+	return NewSRPTranscript(s.Salt, s.A, s.b, s.U, s.clientHmac)
+}
+
 func (s *MitmSimpleSRPServer) CrackPassword() ([]byte, error) {
-	for _, pass := range passwords {
-		// with salt="", B=2, u=1,
-		// x = SHA256(salt|password) = SHA256(password)
-	 	// S = B**(a + ux) % n = B**a * B**ux = 2**a * 2**x = A * 2**x (mod n)
-	 	// K = SHA256(S)
-		// hmac = HMAC-SHA256(K, salt) = HMAC-SHA256(K, "")
-		x := HashStrings(pass).Int()
-
-		S := new(big.Int)
-		S.Exp(mtsn.Big.Two, x, DFConstants.P)
-		S.Mul(S, s.A)
-		S.Mod(S, DFConstants.P)
-
-		K := HashStrings(S.Bytes())
-		hmac := MakeHmac(K, s.Salt)
-
-		if bytes.Equal(hmac, s.clientHmac) {
-			return pass, nil
-		}
+	// with salt="", B=2, u=1,
+	// x = SHA256(salt|password) = SHA256(password)
+	// S = B**(a + ux) % n = B**a * B**ux = 2**a * 2**x = A * 2**x (mod n)
+	// K = SHA256(S)
+	// hmac = HMAC-SHA256(K, salt) = HMAC-SHA256(K, "")
+	words := bytes.Join(passwords, []byte("\n"))
+	result, err := CrackTranscript(context.Background(), s.Transcript(), bytes.NewReader(words), crackRules)
+	if err != nil {
+		return nil, err
 	}
-
-	return nil, fmt.Errorf("Could not crack password from list")
+	if result.Password == nil {
+		return nil, fmt.Errorf("Could not crack password from list")
+	}
+	return result.Password, nil
 }
 
 func NewMitmSimpleSRPServer() *MitmSimpleSRPServer {
@@ -197,4 +198,15 @@ func Challenge38() {
 
 	fmt.Printf("Challenge 38: Works normally? %v mitm found password? %v.\n",
 		normal, bytes.Equal(password, crackedPassword))
+
+	// A mangled password takes the rules to find
+	password = append(CaseRule(password)[3], "42"...)
+	client = NewSimpleSRPClient([]byte("abe@example.com"), password)
+	mitmServer = NewMitmSimpleSRPServer()
+	_ = SimpleSRPExchange(mitmServer, client)
+	result, err := CrackTranscript(context.Background(), mitmServer.Transcript(),
+		bytes.NewReader(bytes.Join(passwords, []byte("\n"))), crackRules)
+	if (err != nil) {panic(err)}
+	fmt.Printf("Challenge 38: mitm found mangled password? %v (%v).\n",
+		bytes.Equal(password, result.Password), result)
 }
@@ -0,0 +1,153 @@
+package set5
+
+import (
+	"fmt"
+	"math/big"
+	"mtsn"
+)
+
+// Primes of the standard Diffie-Hellman groups, all of them safe primes
+// (p = 2q + 1 with q prime) with 2 generating the subgroup of order q.
+const (
+	// RFC 2409 group 1
+	primeModp768 = "ffffffffffffffffc90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74" +
+		"020bbea63b139b22514a08798e3404ddef9519b3cd3a431b302b0a6df25f1437" +
+		"4fe1356d6d51c245e485b576625e7ec6f44c42e9a63a3620ffffffffffffffff"
+	// RFC 2409 group 2
+	primeModp1024 = "ffffffffffffffffc90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74" +
+		"020bbea63b139b22514a08798e3404ddef9519b3cd3a431b302b0a6df25f1437" +
+		"4fe1356d6d51c245e485b576625e7ec6f44c42e9a637ed6b0bff5cb6f406b7ed" +
+		"ee386bfb5a899fa5ae9f24117c4b1fe649286651ece65381ffffffffffffffff"
+	// RFC 3526 group 5
+	primeModp1536 = "ffffffffffffffffc90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74" +
+		"020bbea63b139b22514a08798e3404ddef9519b3cd3a431b302b0a6df25f1437" +
+		"4fe1356d6d51c245e485b576625e7ec6f44c42e9a637ed6b0bff5cb6f406b7ed" +
+		"ee386bfb5a899fa5ae9f24117c4b1fe649286651ece45b3dc2007cb8a163bf05" +
+		"98da48361c55d39a69163fa8fd24cf5f83655d23dca3ad961c62f356208552bb" +
+		"9ed529077096966d670c354e4abc9804f1746c08ca237327ffffffffffffffff"
+	// RFC 3526 group 14
+	primeModp2048 = "ffffffffffffffffc90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74" +
+		"020bbea63b139b22514a08798e3404ddef9519b3cd3a431b302b0a6df25f1437" +
+		"4fe1356d6d51c245e485b576625e7ec6f44c42e9a637ed6b0bff5cb6f406b7ed" +
+		"ee386bfb5a899fa5ae9f24117c4b1fe649286651ece45b3dc2007cb8a163bf05" +
+		"98da48361c55d39a69163fa8fd24cf5f83655d23dca3ad961c62f356208552bb" +
+		"9ed529077096966d670c354e4abc9804f1746c08ca18217c32905e462e36ce3b" +
+		"e39e772c180e86039b2783a2ec07a28fb5c55df06f4c52c9de2bcbf695581718" +
+		"3995497cea956ae515d2261898fa051015728e5a8aacaa68ffffffffffffffff"
+	// RFC 3526 group 15
+	primeModp3072 = "ffffffffffffffffc90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74" +
+		"020bbea63b139b22514a08798e3404ddef9519b3cd3a431b302b0a6df25f1437" +
+		"4fe1356d6d51c245e485b576625e7ec6f44c42e9a637ed6b0bff5cb6f406b7ed" +
+		"ee386bfb5a899fa5ae9f24117c4b1fe649286651ece45b3dc2007cb8a163bf05" +
+		"98da48361c55d39a69163fa8fd24cf5f83655d23dca3ad961c62f356208552bb" +
+		"9ed529077096966d670c354e4abc9804f1746c08ca18217c32905e462e36ce3b" +
+		"e39e772c180e86039b2783a2ec07a28fb5c55df06f4c52c9de2bcbf695581718" +
+		"3995497cea956ae515d2261898fa051015728e5a8aaac42dad33170d04507a33" +
+		"a85521abdf1cba64ecfb850458dbef0a8aea71575d060c7db3970f85a6e1e4c7" +
+		"abf5ae8cdb0933d71e8c94e04a25619dcee3d2261ad2ee6bf12ffa06d98a0864" +
+		"d87602733ec86a64521f2b18177b200cbbe117577a615d6c770988c0bad946e2" +
+		"08e24fa074e5ab3143db5bfce0fd108e4b82d120a93ad2caffffffffffffffff"
+	// RFC 7919
+	primeFfdhe2048 = "ffffffffffffffffadf85458a2bb4a9aafdc5620273d3cf1d8b9c583ce2d3695" +
+		"a9e13641146433fbcc939dce249b3ef97d2fe363630c75d8f681b202aec4617a" +
+		"d3df1ed5d5fd65612433f51f5f066ed0856365553ded1af3b557135e7f57c935" +
+		"984f0c70e0e68b77e2a689daf3efe8721df158a136ade73530acca4f483a797a" +
+		"bc0ab182b324fb61d108a94bb2c8e3fbb96adab760d7f4681d4f42a3de394df4" +
+		"ae56ede76372bb190b07a7c8ee0a6d709e02fce1cdf7e2ecc03404cd28342f61" +
+		"9172fe9ce98583ff8e4f1232eef28183c3fe3b1b4c6fad733bb5fcbc2ec22005" +
+		"c58ef1837d1683b2c6f34a26c1b2effa886b423861285c97ffffffffffffffff"
+	// RFC 7919
+	primeFfdhe3072 = "ffffffffffffffffadf85458a2bb4a9aafdc5620273d3cf1d8b9c583ce2d3695" +
+		"a9e13641146433fbcc939dce249b3ef97d2fe363630c75d8f681b202aec4617a" +
+		"d3df1ed5d5fd65612433f51f5f066ed0856365553ded1af3b557135e7f57c935" +
+		"984f0c70e0e68b77e2a689daf3efe8721df158a136ade73530acca4f483a797a" +
+		"bc0ab182b324fb61d108a94bb2c8e3fbb96adab760d7f4681d4f42a3de394df4" +
+		"ae56ede76372bb190b07a7c8ee0a6d709e02fce1cdf7e2ecc03404cd28342f61" +
+		"9172fe9ce98583ff8e4f1232eef28183c3fe3b1b4c6fad733bb5fcbc2ec22005" +
+		"c58ef1837d1683b2c6f34a26c1b2effa886b4238611fcfdcde355b3b6519035b" +
+		"bc34f4def99c023861b46fc9d6e6c9077ad91d2691f7f7ee598cb0fac186d91c" +
+		"aefe130985139270b4130c93bc437944f4fd4452e2d74dd364f2e21e71f54bff" +
+		"5cae82ab9c9df69ee86d2bc522363a0dabc521979b0deada1dbf9a42d5c4484e" +
+		"0abcd06bfa53ddef3c1b20ee3fd59d7c25e41d2b66c62e37ffffffffffffffff"
+)
+
+// DHGroup is a Diffie-Hellman group: the prime P, the generator G and the
+// order Q of the subgroup G generates.
+type DHGroup struct {
+	Name string
+	P    *big.Int
+	G    *big.Int
+	Q    *big.Int
+}
+
+// newSafePrimeGroup builds a group from a safe prime written in hex, with 2 as
+// its generator.
+func newSafePrimeGroup(name string, hexP string) *DHGroup {
+	p, ok := new(big.Int).SetString(hexP, 16)
+	if !ok {
+		panic(fmt.Errorf("Bad prime for group %s", name))
+	}
+	q := new(big.Int).Sub(p, mtsn.Big.One)
+	q.Rsh(q, 1)
+	return &DHGroup{name, p, big.NewInt(2), q}
+}
+
+// DHGroups holds the standard groups, by name.
+var DHGroups = makeDHGroups()
+
+func makeDHGroups() map[string]*DHGroup {
+	groups := make(map[string]*DHGroup)
+	for _, group := range []*DHGroup{
+		newSafePrimeGroup("modp768", primeModp768),
+		newSafePrimeGroup("modp1024", primeModp1024),
+		newSafePrimeGroup("modp1536", primeModp1536),
+		newSafePrimeGroup("modp2048", primeModp2048),
+		newSafePrimeGroup("modp3072", primeModp3072),
+		newSafePrimeGroup("ffdhe2048", primeFfdhe2048),
+		newSafePrimeGroup("ffdhe3072", primeFfdhe3072),
+	} {
+		groups[group.Name] = group
+	}
+	return groups
+}
+
+// LookupDHGroup finds a standard group by name.
+func LookupDHGroup(name string) (*DHGroup, error) {
+	group, found := DHGroups[name]
+	if !found {
+		return nil, fmt.Errorf("No Diffie-Hellman group called %q", name)
+	}
+	return group, nil
+}
+
+// FindDHGroup finds the standard group with prime p and generator g, which
+// is how a party can check the parameters it is handed.
+func FindDHGroup(p *big.Int, g *big.Int) (*DHGroup, error) {
+	if p == nil || g == nil {
+		return nil, fmt.Errorf("Missing Diffie-Hellman parameters")
+	}
+	for _, group := range DHGroups {
+		if group.P.Cmp(p) == 0 && group.G.Cmp(g) == 0 {
+			return group, nil
+		}
+	}
+	return nil, fmt.Errorf("Parameters p of %d bits, g=%v are not a known group", p.BitLen(), g)
+}
+
+// ValidatePublic checks that a public value received from the other party
+// is in range (1 < y < p - 1) and sits in the subgroup of order Q, so that
+// it cannot be used to pin the session key to a handful of values.
+func (group *DHGroup) ValidatePublic(y *big.Int) error {
+	if y == nil {
+		return fmt.Errorf("Missing public value")
+	}
+
+	pMinusOne := new(big.Int).Sub(group.P, mtsn.Big.One)
+	if y.Cmp(mtsn.Big.One) <= 0 || y.Cmp(pMinusOne) >= 0 {
+		return fmt.Errorf("Public value %v is out of range for group %s", y, group.Name)
+	}
+	if group.Q != nil && new(big.Int).Exp(y, group.Q, group.P).Cmp(mtsn.Big.One) != 0 {
+		return fmt.Errorf("Public value is not in the subgroup of order q for group %s", group.Name)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package set5
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestDHGroupsAreSafePrimes(t *testing.T) {
+	for name, group := range DHGroups {
+		if group.Name != name {
+			t.Errorf("Group %s is registered as %s", group.Name, name)
+		}
+		if !group.P.ProbablyPrime(20) {
+			t.Errorf("%s: p is not prime", name)
+		}
+		if !group.Q.ProbablyPrime(20) {
+			t.Errorf("%s: q is not prime", name)
+		}
+		q := new(big.Int).Lsh(group.Q, 1)
+		if q.Add(q, big.NewInt(1)).Cmp(group.P) != 0 {
+			t.Errorf("%s: p is not 2q + 1", name)
+		}
+		if new(big.Int).Exp(group.G, group.Q, group.P).Cmp(big.NewInt(1)) != 0 {
+			t.Errorf("%s: g does not generate the subgroup of order q", name)
+		}
+	}
+}
+
+func TestValidatePublic(t *testing.T) {
+	group, err := LookupDHGroup("modp1536")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := group.P
+	pMinus := func(n int64) *big.Int { return new(big.Int).Sub(p, big.NewInt(n)) }
+
+	// As p = 3 mod 4, -1 is not a square, and neither is -4
+	bad := map[string]*big.Int{
+		"nil":          nil,
+		"0":            big.NewInt(0),
+		"1":            big.NewInt(1),
+		"p - 1":        pMinus(1),
+		"p":            p,
+		"p + 2":        new(big.Int).Add(p, big.NewInt(2)),
+		"negative":     big.NewInt(-2),
+		"not a square": pMinus(4),
+	}
+	for name, y := range bad {
+		if err := group.ValidatePublic(y); err == nil {
+			t.Errorf("Accepted %s as a public value", name)
+		}
+	}
+
+	dh, err := NewDiffieHellmanGroup(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, y := range []*big.Int{big.NewInt(4), dh.MyPublic} {
+		if err := group.ValidatePublic(y); err != nil {
+			t.Errorf("Refused %v: %v", y, err)
+		}
+	}
+}
+
+func TestFindDHGroup(t *testing.T) {
+	for name, group := range DHGroups {
+		found, err := FindDHGroup(new(big.Int).Set(group.P), big.NewInt(2))
+		if err != nil || found != group {
+			t.Errorf("%s: found %v (%v)", name, found, err)
+		}
+	}
+
+	modp := DHGroups["modp1024"]
+	unknown := map[string][2]*big.Int{
+		"zero p":     {big.NewInt(0), big.NewInt(2)},
+		"tiny p":     {big.NewInt(23), big.NewInt(2)},
+		"other g":    {modp.P, big.NewInt(5)},
+		"g = p - 1":  {modp.P, new(big.Int).Sub(modp.P, big.NewInt(1))},
+		"p off by 2": {new(big.Int).Add(modp.P, big.NewInt(2)), big.NewInt(2)},
+		"missing p":  {nil, big.NewInt(2)},
+		"missing g":  {modp.P, nil},
+	}
+	for name, pg := range unknown {
+		if group, err := FindDHGroup(pg[0], pg[1]); err == nil {
+			t.Errorf("%s: found group %s", name, group.Name)
+		}
+	}
+}
+
+func TestValidatingParty(t *testing.T) {
+	group := DHGroups["modp1536"]
+	msg := []byte("Hello B")
+
+	if err := ExchangeMessage(NewNormalParty(msg, group, true), NewNormalParty(nil, nil, true)); err != nil {
+		t.Errorf("Validating parties could not talk: %v", err)
+	}
+
+	// Unknown parameters
+	custom, err := NewDiffieHellman(big.NewInt(23), big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewNormalParty(nil, nil, true)
+	if _, err := b.RespondToInit(&DFInit{custom.P, custom.G, custom.MyPublic}); err == nil {
+		t.Errorf("Validating party accepted an unknown group")
+	}
+
+	// Bad public values, either way
+	for _, y := range []*big.Int{big.NewInt(1), group.P, new(big.Int).Sub(group.P, big.NewInt(1))} {
+		b := NewNormalParty(nil, nil, true)
+		if _, err := b.RespondToInit(&DFInit{group.P, group.G, y}); err == nil {
+			t.Errorf("Validating party accepted %v from the initiator", y)
+		}
+
+		a := NewNormalParty(msg, group, true)
+		a.InitDF()
+		if _, err := a.SendMsg(y); err == nil {
+			t.Errorf("Validating party accepted %v in response", y)
+		}
+	}
+
+	// And a MITM handing out p is caught
+	mitm := NewMITM(NewNormalParty(msg, group, true), NewNormalParty(nil, nil, true))
+	if err := ExchangeMessage(mitm, mitm); err == nil {
+		t.Errorf("Validating parties did not notice the MITM")
+	}
+}
@@ -1,25 +1,15 @@
 package set5
 
 import (
+	"fmt"
 	"math/big"
 	"crypto/rand"
 	"mtsn"
 )
 
+// PConstant returns the 1536-bit prime from RFC 3526.
 func PConstant() *big.Int {
-	p := new(big.Int)
-	p.SetString(
-		"ffffffffffffffffc90fdaa22168c234c4c6628b80dc1cd129024" +
-        "e088a67cc74020bbea63b139b22514a08798e3404ddef9519b3cd" +
-        "3a431b302b0a6df25f14374fe1356d6d51c245e485b576625e7ec" +
-        "6f44c42e9a637ed6b0bff5cb6f406b7edee386bfb5a899fa5ae9f" +
-        "24117c4b1fe649286651ece45b3dc2007cb8a163bf0598da48361" +
-        "c55d39a69163fa8fd24cf5f83655d23dca3ad961c62f356208552" +
-        "bb9ed529077096966d670c354e4abc9804f1746c08ca237327fff" +
-        "fffffffffffff",
-        16,
-    )
-    return p
+	return new(big.Int).Set(DHGroups["modp1536"].P)
 }
 
 // Constants used in Diffie Hellman exchange
@@ -31,17 +21,28 @@ var DFConstants = struct {
 	mtsn.Big.Two,
 }
 
-// Contains values used in one part of a Diffie-Hellman key exchange.
+// Contains values used in one part of a Diffie-Hellman key exchange. Group
+// is only set when the parameters are a known group, and is what gets
+// used to validate the other party's public value.
 type DiffieHellman struct {
 	P *big.Int
 	G *big.Int
+	Group *DHGroup
 	MyPrivate *big.Int
 	MyPublic *big.Int
 }
 
 // NewDiffieHellman sets up the neccessary parts for one of two parties in a
-// Diffie-Hellman key exchange.
-func NewDiffieHellman(p *big.Int, g *big.Int) *DiffieHellman{
+// Diffie-Hellman key exchange. Nothing checks that p and g make sense, see
+// NewDiffieHellmanGroup for that.
+func NewDiffieHellman(p *big.Int, g *big.Int) (*DiffieHellman, error) {
+	if p == nil || g == nil {
+		return nil, fmt.Errorf("NewDiffieHellman: missing p or g")
+	}
+	if p.Cmp(mtsn.Big.Two) <= 0 {
+		return nil, fmt.Errorf("NewDiffieHellman: p must be more than 2, not %v", p)
+	}
+
 	output := new(DiffieHellman)
 	var err error
 
@@ -52,7 +53,7 @@ func NewDiffieHellman(p *big.Int, g *big.Int) *DiffieHellman{
 		rand.Reader,
 		output.P,
 	)
-	if (err != nil) {panic(err)}
+	if (err != nil) {return nil, err}
 
 	output.MyPublic.Exp(
 		output.G,
@@ -60,7 +61,21 @@ func NewDiffieHellman(p *big.Int, g *big.Int) *DiffieHellman{
 		output.P,
 	)
 
-	return output
+	return output, nil
+}
+
+// NewDiffieHellmanGroup sets up one party of a key exchange in a known
+// group, keeping the private key below the order of the subgroup.
+func NewDiffieHellmanGroup(group *DHGroup) (*DiffieHellman, error) {
+	output, err := NewDiffieHellman(group.P, group.G)
+	if (err != nil) {return nil, err}
+	output.Group = group
+
+	output.MyPrivate, err = rand.Int(rand.Reader, group.Q)
+	if (err != nil) {return nil, err}
+	output.MyPublic.Exp(group.G, output.MyPrivate, group.P)
+
+	return output, nil
 }
 
 // SessionKey produces the session key the two parties of a Diffie-Hellman key
@@ -74,3 +89,16 @@ func (d *DiffieHellman) SessionKey(otherPublic *big.Int) *big.Int {
 	)
 	return session
 }
+
+// ValidSessionKey produces the session key like SessionKey, once otherPublic
+// has passed the checks of d.Group. Without a group, it can only check that
+// otherPublic is in range.
+func (d *DiffieHellman) ValidSessionKey(otherPublic *big.Int) (*big.Int, error) {
+	group := d.Group
+	if group == nil {
+		group = &DHGroup{Name: "custom", P: d.P, G: d.G}
+	}
+	err := group.ValidatePublic(otherPublic)
+	if (err != nil) {return nil, err}
+	return d.SessionKey(otherPublic), nil
+}
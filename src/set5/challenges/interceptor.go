@@ -0,0 +1,180 @@
+package set5
+
+import (
+	"fmt"
+	"math/big"
+	"mtsn"
+	"sha1hacks"
+)
+
+// Session is what an Interceptor has seen of an exchange so far, both as the
+// parties sent it and as it was passed on.
+type Session struct {
+	// From partyA to partyB
+	Init          *DFInit
+	ForwardedInit *DFInit
+
+	// From partyB to partyA
+	Response          *big.Int
+	ForwardedResponse *big.Int
+
+	// The encrypted message and its acknowledgement, as passed on
+	Msg []byte
+	Ack []byte
+
+	// Whatever the hooks managed to decrypt
+	Plaintext []byte
+}
+
+// Hooks get to look at a message going through an Interceptor and change it.
+// Returning an error stops the exchange.
+type InitHook func(s *Session, init *DFInit) (*DFInit, error)
+type PublicHook func(s *Session, public *big.Int) (*big.Int, error)
+type PayloadHook func(s *Session, payload []byte) ([]byte, error)
+
+// Interceptor is a Man-In-The-Middle for the ExchangeMessage protocol which
+// passes everything between partyA and partyB through the hooks registered
+// for it, in the order they were registered. Without any hooks it changes
+// nothing.
+//
+// Example usage, for a MITM swapping the public values for p:
+//
+//    mitm := NewInterceptor(partyA, partyB)
+//    mitm.OnInit(func(s *Session, init *DFInit) (*DFInit, error) {
+//        return &DFInit{init.P, init.G, init.P}, nil
+//    })
+//    err := ExchangeMessage(mitm, mitm)
+type Interceptor struct {
+	partyA  Party
+	partyB  Party
+	Session *Session
+
+	initHooks     []InitHook
+	responseHooks []PublicHook
+	msgHooks      []PayloadHook
+	ackHooks      []PayloadHook
+}
+
+func NewInterceptor(partyA Party, partyB Party) *Interceptor {
+	return &Interceptor{partyA: partyA, partyB: partyB, Session: new(Session)}
+}
+
+// OnInit registers a hook for partyA's DFInit on its way to partyB.
+func (m *Interceptor) OnInit(hook InitHook) {
+	m.initHooks = append(m.initHooks, hook)
+}
+
+// OnInitResponse registers a hook for partyB's public value on its way to
+// partyA.
+func (m *Interceptor) OnInitResponse(hook PublicHook) {
+	m.responseHooks = append(m.responseHooks, hook)
+}
+
+// OnMsg registers a hook for partyA's encrypted message on its way to partyB.
+func (m *Interceptor) OnMsg(hook PayloadHook) {
+	m.msgHooks = append(m.msgHooks, hook)
+}
+
+// OnAck registers a hook for partyB's acknowledgement on its way to partyA.
+func (m *Interceptor) OnAck(hook PayloadHook) {
+	m.ackHooks = append(m.ackHooks, hook)
+}
+
+func (m *Interceptor) InitDF() *DFInit {
+	m.Session.Init = m.partyA.InitDF()
+	return m.Session.Init
+}
+
+func (m *Interceptor) RespondToInit(init *DFInit) (*big.Int, error) {
+	var err error
+	for _, hook := range m.initHooks {
+		if init, err = hook(m.Session, init); err != nil {
+			return nil, err
+		}
+	}
+	m.Session.ForwardedInit = init
+
+	public, err := m.partyB.RespondToInit(init)
+	if err != nil {
+		return nil, err
+	}
+	m.Session.Response = public
+	return public, nil
+}
+
+func (m *Interceptor) SendMsg(otherPublicKey *big.Int) ([]byte, error) {
+	var err error
+	for _, hook := range m.responseHooks {
+		if otherPublicKey, err = hook(m.Session, otherPublicKey); err != nil {
+			return nil, err
+		}
+	}
+	m.Session.ForwardedResponse = otherPublicKey
+
+	payload, err := m.partyA.SendMsg(otherPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range m.msgHooks {
+		if payload, err = hook(m.Session, payload); err != nil {
+			return nil, err
+		}
+	}
+	m.Session.Msg = payload
+	return payload, nil
+}
+
+func (m *Interceptor) AcknowlegeMsg(payload []byte) ([]byte, error) {
+	ack, err := m.partyB.AcknowlegeMsg(payload)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range m.ackHooks {
+		if ack, err = hook(m.Session, ack); err != nil {
+			return nil, err
+		}
+	}
+	m.Session.Ack = ack
+	return ack, nil
+}
+
+func (m *Interceptor) AcknowlegeResponse(payload []byte) error {
+	return m.partyA.AcknowlegeResponse(payload)
+}
+
+// DecryptWithSessionKey decrypts a payload (iv at the end) sent by one of the
+// parties, given the session key it was sent with.
+func DecryptWithSessionKey(sessionKey *big.Int, payload []byte) ([]byte, error) {
+	if len(payload) < 16 {
+		return nil, fmt.Errorf("DecryptWithSessionKey: payload has no room for a 16 byte iv")
+	}
+	encrypted := payload[0 : len(payload)-16]
+	iv := payload[len(payload)-16:]
+
+	key := sha1hacks.Sum(sessionKey.Bytes())
+	decrypted, err := mtsn.DecryptAesCbc(key[0:16], iv, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	return mtsn.StripPkcs7(decrypted)
+}
+
+// DecryptWith is a PayloadHook which decrypts the payload into
+// Session.Plaintext using the first of sessionKeys that works, and passes it
+// on untouched. It fails if none of them work, or if there are none.
+func DecryptWith(sessionKeys ...*big.Int) PayloadHook {
+	return func(s *Session, payload []byte) ([]byte, error) {
+		if len(sessionKeys) == 0 {
+			return nil, fmt.Errorf("DecryptWith: no session keys to try")
+		}
+		var err error
+		for _, key := range sessionKeys {
+			var plaintext []byte
+			if plaintext, err = DecryptWithSessionKey(key, payload); err == nil {
+				s.Plaintext = plaintext
+				return payload, nil
+			}
+		}
+		return nil, err
+	}
+}
@@ -0,0 +1,197 @@
+package set5
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"mtsn"
+	"strings"
+	"testing"
+)
+
+// newExchange sets up an Interceptor between two fresh parties, with partyA
+// sending msg.
+func newExchange(msg []byte) (*Interceptor, *NormalParty, *NormalParty) {
+	a := NewNormalParty(msg, DHGroups["modp1536"], false)
+	b := NewNormalParty(nil, nil, false)
+	return NewInterceptor(a, b), a, b
+}
+
+func TestInterceptorPassesThrough(t *testing.T) {
+	msg := []byte("Hello B")
+	mitm, a, b := newExchange(msg)
+	if err := ExchangeMessage(mitm, mitm); err != nil {
+		t.Fatal(err)
+	}
+
+	s := mitm.Session
+	if s.Init == nil || s.ForwardedInit != s.Init {
+		t.Errorf("Init %v was forwarded as %v", s.Init, s.ForwardedInit)
+	}
+	if s.Init.Public.Cmp(a.df.MyPublic) != 0 {
+		t.Errorf("Recorded init does not come from partyA")
+	}
+	if s.Response == nil || s.ForwardedResponse != s.Response || s.Response.Cmp(b.df.MyPublic) != 0 {
+		t.Errorf("Response %v was forwarded as %v", s.Response, s.ForwardedResponse)
+	}
+	if len(s.Msg) == 0 || len(s.Ack) == 0 {
+		t.Errorf("Message or acknowledgement missing from the session")
+	}
+	if s.Plaintext != nil {
+		t.Errorf("Read %q without any hooks", s.Plaintext)
+	}
+}
+
+func TestInterceptorHookOrder(t *testing.T) {
+	mitm, _, _ := newExchange([]byte("Hello B"))
+
+	// Each hook sees what the one before returned
+	var calls []string
+	mitm.OnInit(func(s *Session, init *DFInit) (*DFInit, error) {
+		calls = append(calls, "init 1")
+		return &DFInit{init.P, init.G, new(big.Int).Set(init.Public)}, nil
+	})
+	mitm.OnInit(func(s *Session, init *DFInit) (*DFInit, error) {
+		calls = append(calls, "init 2")
+		if init == s.Init {
+			t.Errorf("Second hook got the original init")
+		}
+		return init, nil
+	})
+	mitm.OnInitResponse(func(s *Session, public *big.Int) (*big.Int, error) {
+		calls = append(calls, "response")
+		return public, nil
+	})
+	for _, name := range []string{"msg 1", "msg 2"} {
+		name := name
+		mitm.OnMsg(func(s *Session, payload []byte) ([]byte, error) {
+			calls = append(calls, name)
+			return payload, nil
+		})
+	}
+	mitm.OnAck(func(s *Session, payload []byte) ([]byte, error) {
+		calls = append(calls, "ack")
+		return payload, nil
+	})
+
+	if err := ExchangeMessage(mitm, mitm); err != nil {
+		t.Fatal(err)
+	}
+	expected := "init 1, init 2, response, msg 1, msg 2, ack"
+	if got := strings.Join(calls, ", "); got != expected {
+		t.Errorf("Hooks ran as %s, expected %s", got, expected)
+	}
+	if mitm.Session.ForwardedInit == mitm.Session.Init {
+		t.Errorf("Session did not record the init as changed by the hooks")
+	}
+}
+
+func TestInterceptorOnAck(t *testing.T) {
+	mitm, _, _ := newExchange([]byte("Hello B"))
+	var seen []byte
+	mitm.OnAck(func(s *Session, payload []byte) ([]byte, error) {
+		seen = append([]byte{}, payload...)
+		tampered := append([]byte{}, payload...)
+		tampered[0] ^= 1
+		return tampered, nil
+	})
+
+	// partyA notices the acknowledgement does not decrypt to its message
+	if err := ExchangeMessage(mitm, mitm); err == nil {
+		t.Errorf("Tampered acknowledgement went through")
+	}
+	if seen == nil {
+		t.Fatalf("OnAck hook never ran")
+	}
+	if bytes.Equal(seen, mitm.Session.Ack) || len(seen) != len(mitm.Session.Ack) {
+		t.Errorf("Session recorded the acknowledgement before the hook")
+	}
+}
+
+func TestInterceptorHookErrors(t *testing.T) {
+	stop := errors.New("stop")
+	tests := []struct {
+		name  string
+		setup func(m *Interceptor)
+		check func(s *Session) bool
+	}{
+		{"init", func(m *Interceptor) {
+			m.OnInit(func(s *Session, init *DFInit) (*DFInit, error) { return nil, stop })
+		}, func(s *Session) bool { return s.ForwardedInit == nil && s.Response == nil }},
+		{"response", func(m *Interceptor) {
+			m.OnInitResponse(func(s *Session, public *big.Int) (*big.Int, error) { return nil, stop })
+		}, func(s *Session) bool { return s.Response != nil && s.ForwardedResponse == nil && s.Msg == nil }},
+		{"msg", func(m *Interceptor) {
+			m.OnMsg(func(s *Session, payload []byte) ([]byte, error) { return nil, stop })
+		}, func(s *Session) bool { return s.ForwardedResponse != nil && s.Msg == nil && s.Ack == nil }},
+		{"ack", func(m *Interceptor) {
+			m.OnAck(func(s *Session, payload []byte) ([]byte, error) { return nil, stop })
+		}, func(s *Session) bool { return s.Msg != nil && s.Ack == nil }},
+	}
+	for _, test := range tests {
+		mitm, _, _ := newExchange([]byte("Hello B"))
+		test.setup(mitm)
+
+		// Nothing runs past the failing hook, down to the last one
+		ran := false
+		mitm.OnAck(func(s *Session, payload []byte) ([]byte, error) {
+			ran = true
+			return payload, nil
+		})
+
+		if err := ExchangeMessage(mitm, mitm); err != stop {
+			t.Errorf("%s: exchange returned %v instead of the hook's error", test.name, err)
+		}
+		if !test.check(mitm.Session) {
+			t.Errorf("%s: exchange went on past the hook, session is %+v", test.name, mitm.Session)
+		}
+		if ran {
+			t.Errorf("%s: hooks ran after the error", test.name)
+		}
+	}
+}
+
+func TestDecryptWith(t *testing.T) {
+	msg := []byte("Hello B")
+	a, err := NewDiffieHellmanGroup(DHGroups["modp1536"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewDiffieHellmanGroup(DHGroups["modp1536"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := mtsn.GenerateRandomKey()
+	encrypted, err := EncryptPayLoad(a, b.MyPublic, msg, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := append(encrypted, iv...)
+	key := a.SessionKey(b.MyPublic)
+
+	s := new(Session)
+	passed, err := DecryptWith(mtsn.Big.One, key)(s, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(passed, payload) {
+		t.Errorf("DecryptWith changed the payload")
+	}
+	if !bytes.Equal(s.Plaintext, msg) {
+		t.Errorf("Decrypted %q, expected %q", s.Plaintext, msg)
+	}
+
+	failures := map[string]PayloadHook{
+		"wrong key": DecryptWith(mtsn.Big.One),
+		"no keys":   DecryptWith(),
+	}
+	for name, hook := range failures {
+		s := new(Session)
+		if passed, err := hook(s, payload); err == nil || passed != nil || s.Plaintext != nil {
+			t.Errorf("%s: got %x, %q (%v)", name, passed, s.Plaintext, err)
+		}
+	}
+	if _, err := DecryptWith(key)(new(Session), iv[:8]); err == nil {
+		t.Errorf("Decrypted a payload without room for an iv")
+	}
+}
@@ -0,0 +1,113 @@
+package set5
+
+import (
+	"net"
+)
+
+// MitmFactory builds a Man-In-The-Middle sitting between partyA and partyB,
+// such as NewMITM.
+type MitmFactory func(partyA Party, partyB Party) Party
+
+// ProxyConn runs one exchange started by the party at the other end of
+// client, and answered by the one at the other end of server, through the
+// Mitm newMitm builds. It returns the Mitm, for whatever it found out. If the
+// exchange fails, the client is told why.
+func ProxyConn(client net.Conn, server net.Conn, newMitm MitmFactory) (Party, error) {
+	initiator, err := NewRemoteInitiator(client)
+	if err != nil {
+		return nil, err
+	}
+
+	mitm := newMitm(initiator, NewRemoteResponder(server))
+	err = ExchangeMessage(mitm, mitm)
+	if err != nil {
+		initiator.Fail(err)
+	}
+	return mitm, err
+}
+
+// MitmProxy accepts connections, dials the real server for each of them, and
+// runs the exchange through a fresh Mitm.
+type MitmProxy struct {
+	Dial    func() (net.Conn, error)
+	NewMitm MitmFactory
+
+	// Done, if set, gets every Mitm once its exchange is over, along with
+	// how it went.
+	Done func(Party, error)
+}
+
+// Serve handles connections from l until it fails, usually because l was
+// closed.
+func (p *MitmProxy) Serve(l net.Listener) error {
+	for {
+		client, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go p.handle(client)
+	}
+}
+
+func (p *MitmProxy) handle(client net.Conn) {
+	defer client.Close()
+
+	server, err := p.Dial()
+	if err != nil {
+		if p.Done != nil {
+			p.Done(nil, err)
+		}
+		return
+	}
+	defer server.Close()
+
+	mitm, err := ProxyConn(client, server, p.NewMitm)
+	if p.Done != nil {
+		p.Done(mitm, err)
+	}
+}
+
+// ExchangeThroughProxy runs ExchangeMessage between partyA and partyB over
+// loopback TCP, through a MitmProxy hosting the Mitm newMitm builds. It
+// returns that Mitm, along with how the exchange went for partyA.
+func ExchangeThroughProxy(partyA Party, partyB Party, newMitm MitmFactory) (Party, error) {
+	serverListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer serverListener.Close()
+	go func() {
+		conn, err := serverListener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		ServeParty(conn, partyB)
+	}()
+
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer proxyListener.Close()
+
+	mitms := make(chan Party, 1)
+	proxy := &MitmProxy{
+		Dial: func() (net.Conn, error) {
+			return net.Dial("tcp", serverListener.Addr().String())
+		},
+		NewMitm: newMitm,
+		Done: func(mitm Party, err error) {
+			mitms <- mitm
+		},
+	}
+	go proxy.Serve(proxyListener)
+
+	conn, err := net.Dial("tcp", proxyListener.Addr().String())
+	if err != nil {
+		return nil, err
+	}
+	err = ExchangeMessage(partyA, NewRemoteResponder(conn))
+	conn.Close()
+	return <-mitms, err
+}
@@ -0,0 +1,96 @@
+package set5
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestProxyConn(t *testing.T) {
+	group := DHGroups["modp1536"]
+	msg := []byte("Hello B")
+
+	// a <-> proxy <-> b, every link being a pipe
+	aConn, proxyClient := net.Pipe()
+	proxyServer, bConn := net.Pipe()
+	defer aConn.Close()
+	defer bConn.Close()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- ServeParty(bConn, NewNormalParty(nil, nil, false))
+	}()
+	proxied := make(chan error, 1)
+	var mitm Party
+	go func() {
+		var err error
+		mitm, err = ProxyConn(proxyClient, proxyServer, func(a Party, b Party) Party {
+			return NewMITM(a, b)
+		})
+		proxied <- err
+	}()
+
+	if err := ExchangeMessage(NewNormalParty(msg, group, false), NewRemoteResponder(aConn)); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-proxied; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	if got := mitm.(*Interceptor).Session.Plaintext; !bytes.Equal(got, msg) {
+		t.Errorf("MITM read %q, want %q", got, msg)
+	}
+}
+
+func TestProxyConnFailure(t *testing.T) {
+	group := DHGroups["modp1536"]
+	aConn, proxyClient := net.Pipe()
+	proxyServer, bConn := net.Pipe()
+	defer aConn.Close()
+	defer bConn.Close()
+
+	// A validating server refuses the MITM's public value, and the client
+	// gets told why
+	go ServeParty(bConn, NewNormalParty(nil, nil, true))
+	go ProxyConn(proxyClient, proxyServer, func(a Party, b Party) Party {
+		return NewMITM(a, b)
+	})
+
+	err := ExchangeMessage(NewNormalParty([]byte("Hello B"), group, false), NewRemoteResponder(aConn))
+	if err == nil {
+		t.Errorf("Exchange went through a MITM and a validating server")
+	}
+}
+
+func TestNormalPartyChecks(t *testing.T) {
+	group := DHGroups["modp1536"]
+	a := NewNormalParty([]byte("Hello B"), group, false)
+	if err := a.AcknowlegeResponse(make([]byte, 16)); err == nil {
+		t.Errorf("Acknowleged a response before exchanging keys")
+	}
+
+	b := NewNormalParty(nil, nil, false)
+	public, err := b.RespondToInit(a.InitDF())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.SendMsg(public); err != nil {
+		t.Fatal(err)
+	}
+	for _, short := range [][]byte{nil, make([]byte, 15)} {
+		if err := a.AcknowlegeResponse(short); err == nil {
+			t.Errorf("Acknowleged a %d byte response", len(short))
+		}
+		if _, err := b.AcknowlegeMsg(short); err == nil {
+			t.Errorf("Acknowleged a %d byte message", len(short))
+		}
+	}
+	if err := b.AcknowlegeResponse(make([]byte, 32)); err == nil {
+		t.Errorf("Responder acknowleged a response")
+	}
+	if _, err := NewNormalParty([]byte("Hello B"), nil, false).SendMsg(public); err == nil {
+		t.Errorf("Sent a message without DF initialized")
+	}
+}
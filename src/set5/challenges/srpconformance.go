@@ -0,0 +1,70 @@
+package set5
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"srp"
+)
+
+// srpServerSession is a srp.Target for one login to an SRPServer.
+type srpServerSession struct {
+	server *SRPServer
+}
+
+func (s *srpServerSession) Start(A *big.Int) ([]byte, *big.Int, error) {
+	s.server.A = A
+	client := new(SRPClient)
+	s.server.Step2(client)
+	return client.Salt, client.B, nil
+}
+
+func (s *srpServerSession) Finish(proof []byte) error {
+	s.server.Step3()
+	if !s.server.Step5(proof) {
+		return fmt.Errorf("Server refused the proof")
+	}
+	return nil
+}
+
+// NewSRPServerSubject sets up an account on SRPServer, or on the validating
+// version of it, for srp.RunAttacks to try its cases against.
+func NewSRPServerSubject(email []byte, password []byte, validate bool) *srp.Subject {
+	account := NewSRPServer(password)
+	account.email = email
+	account.validate = validate
+
+	name := "SRPServer"
+	if validate {
+		name = "Validating SRPServer"
+	}
+	return &srp.Subject{
+		Name: name,
+		N:    DFConstants.P,
+		NewSession: func() (srp.Target, error) {
+			// Same salt and verifier, fresh b
+			server := *account
+			b, err := rand.Int(rand.Reader, DFConstants.P)
+			if err != nil {
+				return nil, err
+			}
+			server.b = b
+			return &srpServerSession{&server}, nil
+		},
+		Proof: func(premaster *big.Int, salt []byte, A *big.Int, B *big.Int) []byte {
+			return MakeHmac(HashStrings(premaster.Bytes()), salt)
+		},
+		Login: func(session srp.Target) (*big.Int, []byte, error) {
+			client := NewSRPClient(email, password)
+			client.pubA = new(big.Int).Exp(SRP.G, client.a, DFConstants.P)
+			salt, B, err := session.Start(client.pubA)
+			if err != nil {
+				return nil, nil, err
+			}
+			client.Step2(salt, B)
+			client.Step3()
+			proof := client.Step4()
+			return client.pubA, proof, session.Finish(proof)
+		},
+	}
+}
@@ -0,0 +1,291 @@
+package set5
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"mtsn"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SRPTranscript is what a malicious SimpleSRPServer gets to see of an
+// exchange, along with the private b it used. It is enough to check
+// password guesses offline.
+type SRPTranscript struct {
+	Salt       []byte
+	A          *big.Int
+	B          *big.Int
+	U          *big.Int
+	ClientHmac []byte
+
+	// The server's private value
+	b *big.Int
+}
+
+// NewSRPTranscript builds a transcript out of a captured exchange.
+func NewSRPTranscript(salt []byte, A *big.Int, b *big.Int, u *big.Int, clientHmac []byte) *SRPTranscript {
+	B := new(big.Int).Exp(SRP.G, b, DFConstants.P)
+	return &SRPTranscript{salt, A, B, u, clientHmac, b}
+}
+
+// Check tells whether password would have given the client's HMAC.
+func (t *SRPTranscript) Check(password []byte) bool {
+	return newTranscriptChecker(t).check(password)
+}
+
+// transcriptChecker does the work of SRPTranscript.Check, with the parts that
+// do not depend on the password done once.
+//
+// S = (A * v**u)**b = A**b * g**(x*u*b), and as g**(P-1) = 1 the exponent can
+// be taken mod P-1, which leaves one Exp per guess.
+type transcriptChecker struct {
+	t       *SRPTranscript
+	aPowB   *big.Int
+	ub      *big.Int
+	pMinus1 *big.Int
+}
+
+func newTranscriptChecker(t *SRPTranscript) *transcriptChecker {
+	pMinus1 := new(big.Int).Sub(DFConstants.P, mtsn.Big.One)
+	ub := new(big.Int).Mul(t.U, t.b)
+	return &transcriptChecker{
+		t:       t,
+		aPowB:   new(big.Int).Exp(t.A, t.b, DFConstants.P),
+		ub:      ub.Mod(ub, pMinus1),
+		pMinus1: pMinus1,
+	}
+}
+
+func (c *transcriptChecker) check(password []byte) bool {
+	x := HashStrings(c.t.Salt, password).Int()
+	x.Mul(x, c.ub)
+	x.Mod(x, c.pMinus1)
+
+	S := new(big.Int).Exp(SRP.G, x, DFConstants.P)
+	S.Mul(S, c.aPowB)
+	S.Mod(S, DFConstants.P)
+
+	K := HashStrings(S.Bytes())
+	return bytes.Equal(MakeHmac(K, c.t.Salt), c.t.ClientHmac)
+}
+
+// ManglingRule turns a word into the candidates to try for it. Rules keep the
+// word itself among their candidates, so that they can be chained.
+type ManglingRule func(word []byte) [][]byte
+
+// CaseRule tries the word as is, in lower case, in upper case and capitalized.
+func CaseRule(word []byte) [][]byte {
+	capitalized := bytes.ToLower(word)
+	if r, size := utf8.DecodeRune(capitalized); r != utf8.RuneError {
+		capitalized = append(utf8.AppendRune(nil, unicode.ToUpper(r)), capitalized[size:]...)
+	}
+	return [][]byte{word, bytes.ToLower(word), bytes.ToUpper(word), capitalized}
+}
+
+// SuffixDigitsRule tries the word followed by every number of up to digits
+// digits, "7" and "07" included.
+func SuffixDigitsRule(digits int) ManglingRule {
+	return func(word []byte) [][]byte {
+		out := [][]byte{word}
+		limit := 1
+		for width := 1; width <= digits; width++ {
+			limit *= 10
+			for i := 0; i < limit; i++ {
+				out = append(out, append(append([]byte{}, word...), fmt.Sprintf("%0*d", width, i)...))
+			}
+		}
+		return out
+	}
+}
+
+// Leetspeak substitutions used by LeetRule
+var leet = map[byte]byte{'a': '4', 'e': '3', 'i': '1', 'o': '0', 's': '5', 't': '7'}
+
+// LeetRule tries the word as is and with every letter of leet substituted.
+func LeetRule(word []byte) [][]byte {
+	swapped := make([]byte, len(word))
+	for i, c := range word {
+		if sub, ok := leet[c|0x20]; ok {
+			swapped[i] = sub
+		} else {
+			swapped[i] = c
+		}
+	}
+	return [][]byte{word, swapped}
+}
+
+// Mangle applies rules to word in turn, each to every candidate of the
+// previous ones, and returns the distinct candidates.
+func Mangle(word []byte, rules []ManglingRule) [][]byte {
+	candidates := [][]byte{word}
+	for _, rule := range rules {
+		seen := make(map[string]bool)
+		var next [][]byte
+		for _, candidate := range candidates {
+			for _, mangled := range rule(candidate) {
+				if !seen[string(mangled)] {
+					seen[string(mangled)] = true
+					next = append(next, mangled)
+				}
+			}
+		}
+		candidates = next
+	}
+	return candidates
+}
+
+// CrackConfig tunes CrackTranscript.
+type CrackConfig struct {
+	// Number of goroutines checking guesses, runtime.NumCPU() if 0
+	Workers int
+	Rules   []ManglingRule
+
+	// Progress, if not nil, is told how the crack is going every
+	// ProgressInterval, a second if 0. It gets a result without a Password.
+	Progress         func(*CrackResult)
+	ProgressInterval time.Duration
+}
+
+// CrackResult says how a crack went.
+type CrackResult struct {
+	// The password found, nil if there was none
+	Password []byte
+	Tried    int64
+	Elapsed  time.Duration
+}
+
+// Rate is the number of guesses checked per second.
+func (r *CrackResult) Rate() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Tried) / r.Elapsed.Seconds()
+}
+
+func (r *CrackResult) String() string {
+	found := "no match"
+	if r.Password != nil {
+		found = fmt.Sprintf("matched %q", r.Password)
+	}
+	return fmt.Sprintf("%s after %d guesses in %v (%.0f/s)", found, r.Tried, r.Elapsed, r.Rate())
+}
+
+// CrackTranscript checks every word of words, one per line, and its mangled
+// candidates against t, with a pool of workers. It stops as soon as one of
+// them matches, or when ctx is done. Not finding the password is not an
+// error; the result then has no Password. Progress is no longer called once
+// CrackTranscript returns.
+func CrackTranscript(ctx context.Context, t *SRPTranscript, words io.Reader, config CrackConfig) (*CrackResult, error) {
+	workers := config.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	result := new(CrackResult)
+	var tried int64
+	var once sync.Once
+
+	candidates := make(chan []byte, workers*16)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checker := newTranscriptChecker(t)
+			for candidate := range candidates {
+				if ctx.Err() != nil {
+					// Drain what is left without checking it
+					continue
+				}
+				atomic.AddInt64(&tried, 1)
+				if checker.check(candidate) {
+					once.Do(func() {
+						result.Password = candidate
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	stopProgress := make(chan struct{})
+	var progressDone sync.WaitGroup
+	if config.Progress != nil {
+		interval := config.ProgressInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		progressDone.Add(1)
+		go func() {
+			defer progressDone.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					config.Progress(&CrackResult{Tried: atomic.LoadInt64(&tried), Elapsed: time.Since(start)})
+				case <-stopProgress:
+					return
+				}
+			}
+		}()
+	}
+
+	scanErr := feedCandidates(ctx, words, config.Rules, candidates)
+	close(candidates)
+	wg.Wait()
+	close(stopProgress)
+	progressDone.Wait()
+
+	result.Tried = atomic.LoadInt64(&tried)
+	result.Elapsed = time.Since(start)
+	if result.Password != nil {
+		return result, nil
+	}
+	if scanErr != nil {
+		return result, scanErr
+	}
+	return result, ctx.Err()
+}
+
+// feedCandidates sends the mangled words of r down out, until r runs out or
+// ctx is done.
+func feedCandidates(ctx context.Context, r io.Reader, rules []ManglingRule, out chan<- []byte) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		word := bytes.TrimRight(scanner.Bytes(), "\r")
+		if len(word) == 0 {
+			continue
+		}
+		for _, candidate := range Mangle(append([]byte{}, word...), rules) {
+			select {
+			case out <- candidate:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// CrackWordlist is CrackTranscript reading the words from the file at path.
+func CrackWordlist(ctx context.Context, t *SRPTranscript, path string, config CrackConfig) (*CrackResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return CrackTranscript(ctx, t, f, config)
+}
@@ -0,0 +1,155 @@
+package set5
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// capture runs a simplified SRP exchange for password through a MITM, and
+// returns what it captured.
+func capture(password string) *SRPTranscript {
+	client := NewSimpleSRPClient([]byte("abe@example.com"), []byte(password))
+	server := NewMitmSimpleSRPServer()
+	SimpleSRPExchange(server, client)
+	return server.Transcript()
+}
+
+// wordlist has n made up words, with extra at position at.
+func wordlist(n int, extra string, at int) string {
+	var words []string
+	for i := 0; i < n; i++ {
+		if i == at {
+			words = append(words, extra)
+		}
+		words = append(words, fmt.Sprintf("word%d", i))
+	}
+	return strings.Join(words, "\n")
+}
+
+// endless is a wordlist which never runs out.
+type endless struct{}
+
+func (endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = "guess\n"[i%6]
+	}
+	return len(p), nil
+}
+
+func TestMangle(t *testing.T) {
+	tests := []struct {
+		word  string
+		rules []ManglingRule
+		want  []string
+	}{
+		{"sea", nil, []string{"sea"}},
+		{"sea", []ManglingRule{CaseRule}, []string{"sea", "SEA", "Sea"}},
+		{"émile", []ManglingRule{CaseRule}, []string{"émile", "ÉMILE", "Émile"}},
+		{"Sea", []ManglingRule{LeetRule}, []string{"Sea", "534"}},
+		{"ab", []ManglingRule{SuffixDigitsRule(1)}, []string{"ab", "ab0", "ab1", "ab2", "ab3", "ab4", "ab5", "ab6", "ab7", "ab8", "ab9"}},
+		{"to", []ManglingRule{CaseRule, LeetRule}, []string{"to", "70", "TO", "To"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, candidate := range Mangle([]byte(test.word), test.rules) {
+			got = append(got, string(candidate))
+		}
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("Mangle(%q) = %q, want %q", test.word, got, test.want)
+		}
+	}
+
+	if n := len(Mangle([]byte("x"), []ManglingRule{SuffixDigitsRule(2)})); n != 111 {
+		t.Errorf("Two digits gave %d candidates, want 111", n)
+	}
+}
+
+func TestCrackTranscript(t *testing.T) {
+	transcript := capture("Dr4g0n7")
+	if !transcript.Check([]byte("Dr4g0n7")) || transcript.Check([]byte("dragon")) {
+		t.Fatalf("Transcript does not check passwords right")
+	}
+	rules := []ManglingRule{CaseRule, LeetRule, SuffixDigitsRule(1)}
+
+	for _, workers := range []int{1, 4} {
+		result, err := CrackTranscript(context.Background(), transcript,
+			strings.NewReader(wordlist(200, "dragon", 10)), CrackConfig{Workers: workers, Rules: rules})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(result.Password) != "Dr4g0n7" {
+			t.Errorf("%d workers found %q", workers, result.Password)
+		}
+
+		// Every word has 4 * 2 * 11 candidates at most, and the crack stops
+		// soon after the 11th word
+		if result.Tried > 20*88 {
+			t.Errorf("%d workers tried %d guesses, which is past the match", workers, result.Tried)
+		}
+	}
+
+	// Not finding the password is not an error
+	result, err := CrackTranscript(context.Background(), transcript,
+		strings.NewReader("dragon\r\n\nbaseball"), CrackConfig{Workers: 2})
+	if err != nil || result.Password != nil {
+		t.Errorf("Got %q (%v) without any rules", result.Password, err)
+	}
+	if result.Tried != 2 {
+		t.Errorf("Tried %d guesses, want 2", result.Tried)
+	}
+}
+
+func TestCrackTranscriptStops(t *testing.T) {
+	transcript := capture("not in any list")
+
+	// Cancelled by the caller
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int64
+	result, err := CrackTranscript(ctx, transcript, endless{}, CrackConfig{
+		Workers:          2,
+		ProgressInterval: 10 * time.Millisecond,
+		Progress: func(progress *CrackResult) {
+			atomic.AddInt64(&calls, 1)
+			if progress.Password != nil {
+				t.Errorf("Progress came with a password")
+			}
+			if progress.Tried > 0 {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Cancelled crack returned %v", err)
+	}
+	if result.Password != nil || result.Tried == 0 {
+		t.Errorf("Cancelled crack gave %v", result)
+	}
+	after := atomic.LoadInt64(&calls)
+	if after == 0 {
+		t.Errorf("Progress was never called")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if atomic.LoadInt64(&calls) != after {
+		t.Errorf("Progress was called after the crack returned")
+	}
+
+	// Broken wordlists
+	broken := map[string]io.Reader{
+		"read error":    iotest.ErrReader(io.ErrUnexpectedEOF),
+		"half read":     io.MultiReader(strings.NewReader("word\n"), iotest.ErrReader(io.ErrUnexpectedEOF)),
+		"line too long": bytes.NewReader(bytes.Repeat([]byte("a"), 1<<17)),
+	}
+	for name, words := range broken {
+		if _, err := CrackTranscript(context.Background(), transcript, words, CrackConfig{Workers: 2}); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package set5
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+)
+
+// Kinds of frame sent over the wire by ExchangeMessage. Every frame is one
+// byte of kind, four bytes of big-endian length and then the body.
+const (
+	// P, G and the public value, each a four byte length and the bytes of
+	// the number
+	FRAME_DF_INIT = iota + 1
+	// The bytes of a public value
+	FRAME_PUBLIC
+	// An encrypted message, iv at the end
+	FRAME_PAYLOAD
+	// Text of an error the other end ran into
+	FRAME_ERROR
+)
+
+// Biggest frame body accepted, which is plenty for a 8192 bit group
+const MAX_FRAME_SIZE = 1 << 16
+
+// WriteFrame sends one frame of kind with body to w.
+func WriteFrame(w io.Writer, kind byte, body []byte) error {
+	if len(body) > MAX_FRAME_SIZE {
+		return fmt.Errorf("WriteFrame: %d bytes is too big for a frame", len(body))
+	}
+	header := make([]byte, 5)
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(body)))
+	_, err := w.Write(append(header, body...))
+	return err
+}
+
+// ReadFrame reads one frame of kind from r. A FRAME_ERROR frame is turned
+// into an error holding its text.
+func ReadFrame(r io.Reader, kind byte) ([]byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > MAX_FRAME_SIZE {
+		return nil, fmt.Errorf("ReadFrame: %d bytes is too big for a frame", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	if header[0] == FRAME_ERROR {
+		return nil, fmt.Errorf("Other end failed: %s", body)
+	}
+	if header[0] != kind {
+		return nil, fmt.Errorf("ReadFrame: expected frame of kind %d, got %d", kind, header[0])
+	}
+	return body, nil
+}
+
+// MarshalDFInit encodes init as the body of a FRAME_DF_INIT. All of its
+// numbers have to be there.
+func MarshalDFInit(init *DFInit) ([]byte, error) {
+	if init == nil || init.P == nil || init.G == nil || init.Public == nil {
+		return nil, fmt.Errorf("MarshalDFInit: missing p, g or public value")
+	}
+	var out []byte
+	for _, n := range []*big.Int{init.P, init.G, init.Public} {
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(n.Bytes())))
+		out = append(append(out, length...), n.Bytes()...)
+	}
+	return out, nil
+}
+
+// UnmarshalDFInit decodes the body of a FRAME_DF_INIT.
+func UnmarshalDFInit(body []byte) (*DFInit, error) {
+	var numbers []*big.Int
+	for i := 0; i < 3; i++ {
+		if len(body) < 4 {
+			return nil, fmt.Errorf("UnmarshalDFInit: body cut short")
+		}
+		length := binary.BigEndian.Uint32(body)
+		body = body[4:]
+		if uint32(len(body)) < length {
+			return nil, fmt.Errorf("UnmarshalDFInit: body cut short")
+		}
+		numbers = append(numbers, new(big.Int).SetBytes(body[:length]))
+		body = body[length:]
+	}
+	if len(body) != 0 {
+		return nil, fmt.Errorf("UnmarshalDFInit: %d bytes left over", len(body))
+	}
+	return &DFInit{numbers[0], numbers[1], numbers[2]}, nil
+}
+
+// RemoteInitiator is the party starting ExchangeMessage, at the other end of
+// conn. It can only play that part: InitDF, SendMsg and AcknowlegeResponse.
+type RemoteInitiator struct {
+	conn net.Conn
+	init *DFInit
+}
+
+// NewRemoteInitiator waits for the party at the other end of conn to start
+// the exchange.
+func NewRemoteInitiator(conn net.Conn) (*RemoteInitiator, error) {
+	body, err := ReadFrame(conn, FRAME_DF_INIT)
+	if err != nil {
+		return nil, err
+	}
+	init, err := UnmarshalDFInit(body)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteInitiator{conn, init}, nil
+}
+
+func (p *RemoteInitiator) InitDF() *DFInit {
+	return p.init
+}
+
+func (p *RemoteInitiator) RespondToInit(*DFInit) (*big.Int, error) {
+	return nil, fmt.Errorf("RespondToInit: remote party is the initiator")
+}
+
+func (p *RemoteInitiator) SendMsg(otherPublicKey *big.Int) ([]byte, error) {
+	err := WriteFrame(p.conn, FRAME_PUBLIC, otherPublicKey.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return ReadFrame(p.conn, FRAME_PAYLOAD)
+}
+
+func (p *RemoteInitiator) AcknowlegeMsg([]byte) ([]byte, error) {
+	return nil, fmt.Errorf("AcknowlegeMsg: remote party is the initiator")
+}
+
+// AcknowlegeResponse passes payload on, leaving the remote party to check it.
+func (p *RemoteInitiator) AcknowlegeResponse(payload []byte) error {
+	return WriteFrame(p.conn, FRAME_PAYLOAD, payload)
+}
+
+// Fail tells the remote party why the exchange stopped.
+func (p *RemoteInitiator) Fail(err error) error {
+	return WriteFrame(p.conn, FRAME_ERROR, []byte(err.Error()))
+}
+
+// RemoteResponder is the party answering ExchangeMessage, at the other end of
+// conn. It can only play that part: RespondToInit and AcknowlegeMsg. InitDF
+// has nothing to offer and returns nil.
+type RemoteResponder struct {
+	conn net.Conn
+}
+
+func NewRemoteResponder(conn net.Conn) *RemoteResponder {
+	return &RemoteResponder{conn}
+}
+
+func (p *RemoteResponder) InitDF() *DFInit {
+	return nil
+}
+
+func (p *RemoteResponder) RespondToInit(init *DFInit) (*big.Int, error) {
+	body, err := MarshalDFInit(init)
+	if err != nil {
+		return nil, err
+	}
+	err = WriteFrame(p.conn, FRAME_DF_INIT, body)
+	if err != nil {
+		return nil, err
+	}
+	body, err = ReadFrame(p.conn, FRAME_PUBLIC)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(body), nil
+}
+
+func (p *RemoteResponder) SendMsg(*big.Int) ([]byte, error) {
+	return nil, fmt.Errorf("SendMsg: remote party is the responder")
+}
+
+func (p *RemoteResponder) AcknowlegeMsg(payload []byte) ([]byte, error) {
+	err := WriteFrame(p.conn, FRAME_PAYLOAD, payload)
+	if err != nil {
+		return nil, err
+	}
+	return ReadFrame(p.conn, FRAME_PAYLOAD)
+}
+
+func (p *RemoteResponder) AcknowlegeResponse([]byte) error {
+	return fmt.Errorf("AcknowlegeResponse: remote party is the responder")
+}
+
+// ServeParty answers the exchange started by whoever is at the other end of
+// conn, as party. If the exchange fails, the other end is told why.
+func ServeParty(conn net.Conn, party Party) error {
+	initiator, err := NewRemoteInitiator(conn)
+	if err != nil {
+		return err
+	}
+	err = ExchangeMessage(initiator, party)
+	if err != nil {
+		initiator.Fail(err)
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package set5
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+)
+
+// pipeFrame writes raw to one end of a pipe and reads a frame of kind from the
+// other.
+func pipeFrame(raw []byte, kind byte) ([]byte, error) {
+	w, r := net.Pipe()
+	defer r.Close()
+	go func() {
+		w.Write(raw)
+		w.Close()
+	}()
+	return ReadFrame(r, kind)
+}
+
+func TestFrames(t *testing.T) {
+	w, r := net.Pipe()
+	defer r.Close()
+	bodies := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte{0xaa}, MAX_FRAME_SIZE)}
+	errs := make(chan error, 1)
+	go func() {
+		defer w.Close()
+		for _, body := range bodies {
+			if err := WriteFrame(w, FRAME_PAYLOAD, body); err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- nil
+	}()
+	for _, body := range bodies {
+		got, err := ReadFrame(r, FRAME_PAYLOAD)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, body) {
+			t.Errorf("Read %d bytes back instead of %d", len(got), len(body))
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFrame(w, FRAME_PAYLOAD, make([]byte, MAX_FRAME_SIZE+1)); err == nil {
+		t.Errorf("Wrote a frame above MAX_FRAME_SIZE")
+	}
+
+	header := make([]byte, 5)
+	header[0] = FRAME_PAYLOAD
+	binary.BigEndian.PutUint32(header[1:], MAX_FRAME_SIZE+1)
+	if _, err := pipeFrame(header, FRAME_PAYLOAD); err == nil {
+		t.Errorf("Read a frame above MAX_FRAME_SIZE")
+	}
+
+	var frame bytes.Buffer
+	WriteFrame(&frame, FRAME_PUBLIC, []byte{1, 2, 3})
+	if _, err := pipeFrame(frame.Bytes(), FRAME_PAYLOAD); err == nil {
+		t.Errorf("Read a frame of the wrong kind")
+	}
+	if _, err := pipeFrame(frame.Bytes()[:6], FRAME_PUBLIC); err == nil {
+		t.Errorf("Read a truncated frame")
+	}
+
+	frame.Reset()
+	WriteFrame(&frame, FRAME_ERROR, []byte("no such group"))
+	if _, err := pipeFrame(frame.Bytes(), FRAME_PAYLOAD); err == nil || !strings.Contains(err.Error(), "no such group") {
+		t.Errorf("FRAME_ERROR gave %v", err)
+	}
+}
+
+func TestDFInitEncoding(t *testing.T) {
+	init := &DFInit{DHGroups["modp1536"].P, big.NewInt(2), big.NewInt(0)}
+	body, err := MarshalDFInit(init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalDFInit(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.P.Cmp(init.P) != 0 || decoded.G.Cmp(init.G) != 0 || decoded.Public.Sign() != 0 {
+		t.Errorf("Decoded %v instead of %v", decoded, init)
+	}
+
+	for i := 0; i < len(body); i++ {
+		if _, err := UnmarshalDFInit(body[:i]); err == nil {
+			t.Errorf("Decoded a body cut short to %d bytes", i)
+		}
+	}
+	if _, err := UnmarshalDFInit(append(body, 0)); err == nil {
+		t.Errorf("Decoded a body with trailing bytes")
+	}
+
+	for _, bad := range []*DFInit{nil, {nil, init.G, init.Public}, {init.P, nil, init.Public}, {init.P, init.G, nil}} {
+		if _, err := MarshalDFInit(bad); err == nil {
+			t.Errorf("Encoded %v", bad)
+		}
+	}
+}
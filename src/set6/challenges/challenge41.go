@@ -7,25 +7,6 @@ import (
 	"mtsn"
 )
 
-type Oracle struct {
-	seen map[string]bool
-	rsa  *mtsn.RSA
-}
-
-func (o *Oracle) Client() *mtsn.RSAClient {
-	return o.rsa.Client()
-}
-
-func (o *Oracle) Decrypt(in *big.Int) ([]byte, error) {
-	_, seen := o.seen[in.String()]
-	if seen {
-		return nil, fmt.Errorf("I have seen this ciphertext before!")
-	}
-	o.seen[in.String()] = true
-	return o.rsa.Decrypt(in), nil
-
-}
-
 // Exactly like mtsn.InvMod, but will panic if it cannot find an inverse
 func InvModPanic(a, n *big.Int) *big.Int {
 	i, err := mtsn.InvMod(a, n)
@@ -35,38 +16,12 @@ func InvModPanic(a, n *big.Int) *big.Int {
 	return i
 }
 
-func Crack(oracle *Oracle, client *mtsn.RSAClient, encrypted *big.Int) []byte {
-	s := mtsn.Big.Three
-	n := (*big.Int)(client)
-
-	//C' = ((S**E mod N) C) mod N
-	cPrime := new(big.Int)
-	cPrime.Exp(s, mtsn.PublicE, n)
-	cPrime.Mul(cPrime, encrypted)
-	cPrime.Mod(cPrime, n)
-
-	pPrimeBytes, err := oracle.Decrypt(cPrime)
-	if err != nil {
-		panic(err)
-	}
-	pPrime := new(big.Int)
-	pPrime.SetBytes(pPrimeBytes)
-
-	//       P'
-	// P = -----  mod N
-	//      S
-	p := InvModPanic(s, n)
-	p.Mul(p, pPrime)
-	p.Mod(p, n)
-	return p.Bytes()
-}
-
 func Challenge41() {
-	oracle := &Oracle{seen: make(map[string]bool), rsa: mtsn.NewRSA()}
+	oracle := mtsn.NewRSAOracle(mtsn.NewRSA(), 0)
 	original := []byte("secret")
-	client := oracle.Client()
+	key := oracle.PublicKey()
 
-	encrypted := client.Encrypt(original)
+	encrypted := key.Encrypt(original)
 
 	decrypted, err := oracle.Decrypt(encrypted)
 	if err != nil {
@@ -81,7 +36,10 @@ func Challenge41() {
 		panic(fmt.Errorf("Expecting error on second decryption"))
 	}
 
-	cracked := Crack(oracle, client, encrypted)
+	cracked, err := mtsn.BlindingAttack(oracle.Decrypt, key, encrypted, nil)
+	if err != nil {
+		panic(err)
+	}
 	if bytes.Equal(cracked, original) {
 		fmt.Printf("Challenge41: cracked %q\n", cracked)
 	} else {
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"crypto/md5"
 	"fmt"
-	"github.com/ALTree/bigfloat"
 	"math/big"
 	"mtsn"
 )
@@ -69,32 +68,6 @@ func (r *RSASigner) Sign(text []byte) []byte {
 	return r.rsa.Decrypt(cleartext)
 }
 
-// Returns the closes interger equal or below the cubic root of n
-func CubeRoot(n *big.Int) *big.Int {
-	base := new(big.Float)
-	base.SetInt(n)
-	exp := big.NewFloat(1.0)
-	exp = exp.Quo(exp, big.NewFloat(3.0))
-	approx := new(big.Int)
-	bigfloat.Pow(base, exp).Int(approx)
-
-	for i := int64(0); ; i++ {
-		newApprox := new(big.Int)
-		newApprox.Add(big.NewInt(i), approx)
-		cube := new(big.Int)
-		cube.Mul(newApprox, newApprox)
-		cube.Mul(cube, newApprox)
-
-		switch cube.Cmp(n) {
-		case 0:
-			return newApprox
-		case 1:
-			return approx.Add(approx, big.NewInt(i-1))
-			// -1 just continues the loop
-		}
-	}
-}
-
 type Cracker struct {
 	payload *big.Int
 	target  *big.Int
@@ -152,7 +125,7 @@ func CrackDigest(text []byte) []byte {
 
 	cracker := &Cracker{target: new(big.Int)}
 	cracker.target.SetBytes(target)
-	cracker.payload = CubeRoot(cracker.target)
+	cracker.payload = mtsn.Root(cracker.target, 3)
 
 	switch cracker.Check() {
 	case -1:
@@ -0,0 +1,47 @@
+package sha512hacks
+
+import (
+	"encoding/binary"
+	"hashhacks"
+)
+
+var block = blockGeneric
+
+// NewState returns a new SHA-512 hash which exposes its internal state.
+func NewState() hashhacks.HashState {
+	d := new(digest)
+	d.Reset()
+	return d
+}
+
+// HackedHasher returns a hash which picks up where prevDigest left off, as if
+// length bytes (padding included) had already been written to it.
+func HackedHasher(prevDigest []byte, length uint64) hashhacks.HashState {
+	d := new(digest)
+	d.len = length
+
+	for i := 0; i < Size; i += 8 {
+		d.h[i/8] = binary.BigEndian.Uint64(prevDigest[i : i+8])
+	}
+	return d
+}
+
+func (d *digest) ChainingValue() []byte {
+	value := make([]byte, Size)
+	for i, word := range d.h {
+		binary.BigEndian.PutUint64(value[i*8:], word)
+	}
+	return value
+}
+
+func (d *digest) Length() uint64 { return d.len }
+
+func (d *digest) Padding(msgLen uint64) []byte {
+	return hashhacks.Padding(msgLen, BlockSize, 16, false)
+}
+
+func (d *digest) Compress(p []byte) {
+	hashhacks.CheckCompress(p, BlockSize, d.nx)
+	block(d, p)
+	d.len += BlockSize
+}
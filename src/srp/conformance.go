@@ -0,0 +1,249 @@
+package srp
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Target is one login attempt against an SRP server, seen from the client's
+// side of the wire.
+type Target interface {
+	// Start sends A, and returns the salt and B the server answered with
+	Start(A *big.Int) ([]byte, *big.Int, error)
+	// Finish sends the client's proof, and fails unless the server lets the
+	// client in
+	Finish(proof []byte) error
+}
+
+// Subject is an SRP server implementation for RunAttacks to try its cases
+// against.
+type Subject struct {
+	Name string
+	// Modulus of the group the server works in
+	N *big.Int
+
+	// NewSession starts a new login attempt for the same account.
+	NewSession func() (Target, error)
+	// Proof is what a client which worked out premaster as S would send,
+	// for the given salt, A and B.
+	Proof func(premaster *big.Int, salt []byte, A *big.Int, B *big.Int) []byte
+	// Login runs an honest client, who knows the password, through session,
+	// and returns the A and proof it sent.
+	Login func(session Target) (*big.Int, []byte, error)
+}
+
+// AttackCase is one row of the table RunAttacks goes through.
+type AttackCase struct {
+	Name string
+	// Legit is set if a correct server should let the client in.
+	Legit bool
+
+	run func(s *Subject) error
+}
+
+// Outcome is how a Subject did on an AttackCase.
+type Outcome struct {
+	Case          string
+	Legit         bool
+	Authenticated bool
+	// Why the client was not let in
+	Err error
+}
+
+// Passed tells whether the Subject behaved as a correct server would.
+func (o *Outcome) Passed() bool {
+	return o.Authenticated == o.Legit
+}
+
+// forgedCase sends the A public builds out of N, and a proof for S = 0, which
+// is what the server gets for any A that is 0 mod N.
+func forgedCase(name string, public func(n *big.Int) *big.Int) AttackCase {
+	return AttackCase{Name: name, run: func(s *Subject) error {
+		session, err := s.NewSession()
+		if err != nil {
+			return err
+		}
+		A := public(s.N)
+		salt, B, err := session.Start(A)
+		if err != nil {
+			return err
+		}
+		return session.Finish(s.Proof(new(big.Int), salt, A, B))
+	}}
+}
+
+// shiftedTarget is a Target which sends shift(A) instead of A.
+type shiftedTarget struct {
+	Target
+	n     *big.Int
+	shift func(A *big.Int, n *big.Int) *big.Int
+}
+
+func (t *shiftedTarget) Start(A *big.Int) ([]byte, *big.Int, error) {
+	return t.Target.Start(t.shift(A, t.n))
+}
+
+// shiftedCase runs an honest client, whose A gets replaced with shift(A, N)
+// on the wire while its proof is left alone. Only a server which takes A
+// mod N rather than refusing it lets that client in.
+func shiftedCase(name string, shift func(A *big.Int, n *big.Int) *big.Int) AttackCase {
+	return AttackCase{Name: name, run: func(s *Subject) error {
+		session, err := s.NewSession()
+		if err != nil {
+			return err
+		}
+		_, _, err = s.Login(&shiftedTarget{session, s.N, shift})
+		return err
+	}}
+}
+
+// randomMultiple is N times a random 64 bit k.
+func randomMultiple(n *big.Int) *big.Int {
+	k, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		panic(err)
+	}
+	return k.Add(k, big.NewInt(3)).Mul(k, n)
+}
+
+// AttackCases are the cases RunAttacks tries, in order.
+var AttackCases = []AttackCase{
+	{Name: "Honest client", Legit: true, run: func(s *Subject) error {
+		session, err := s.NewSession()
+		if err != nil {
+			return err
+		}
+		_, _, err = s.Login(session)
+		return err
+	}},
+	forgedCase("A = 0", func(n *big.Int) *big.Int {
+		return new(big.Int)
+	}),
+	forgedCase("A = N", func(n *big.Int) *big.Int {
+		return new(big.Int).Set(n)
+	}),
+	forgedCase("A = 2N", func(n *big.Int) *big.Int {
+		return new(big.Int).Lsh(n, 1)
+	}),
+	forgedCase("A = N*k", randomMultiple),
+	forgedCase("A = N^2", func(n *big.Int) *big.Int {
+		return new(big.Int).Mul(n, n)
+	}),
+	forgedCase("A = -N", func(n *big.Int) *big.Int {
+		return new(big.Int).Neg(n)
+	}),
+	shiftedCase("A + N", func(A *big.Int, n *big.Int) *big.Int {
+		return new(big.Int).Add(A, n)
+	}),
+	shiftedCase("A - N", func(A *big.Int, n *big.Int) *big.Int {
+		return new(big.Int).Sub(A, n)
+	}),
+	{Name: "Replayed transcript", run: func(s *Subject) error {
+		session, err := s.NewSession()
+		if err != nil {
+			return err
+		}
+		A, proof, err := s.Login(session)
+		if err != nil {
+			return fmt.Errorf("Honest login to record failed: %v", err)
+		}
+
+		replay, err := s.NewSession()
+		if err != nil {
+			return err
+		}
+		if _, _, err := replay.Start(A); err != nil {
+			return err
+		}
+		return replay.Finish(proof)
+	}},
+}
+
+// RunAttacks runs every one of AttackCases against s.
+func RunAttacks(s *Subject) []*Outcome {
+	var outcomes []*Outcome
+	for _, c := range AttackCases {
+		err := c.run(s)
+		outcomes = append(outcomes, &Outcome{c.Name, c.Legit, err == nil, err})
+	}
+	return outcomes
+}
+
+// Report lays out outcomes as a table, one line per case.
+func Report(name string, outcomes []*Outcome) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s:\n", name)
+	for _, o := range outcomes {
+		verdict := "ok"
+		if !o.Passed() {
+			verdict = "FAIL"
+		}
+		result := "refused"
+		if o.Authenticated {
+			result = "authenticated"
+		}
+		fmt.Fprintf(&b, "  %-4s %-20s %s", verdict, o.Case, result)
+		if o.Err != nil {
+			fmt.Fprintf(&b, " (%v)", o.Err)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// serverSession is a Target for a Server.
+type serverSession struct {
+	server *Server
+	A      *big.Int
+}
+
+func (s *serverSession) Start(A *big.Int) ([]byte, *big.Int, error) {
+	s.A = A
+	return s.server.Salt(), s.server.Public(), nil
+}
+
+func (s *serverSession) Finish(proof []byte) error {
+	_, err := s.server.Verify(s.A, proof)
+	return err
+}
+
+// NewServerSubject sets up an account for identity on Server, to run the
+// attack cases against.
+func NewServerSubject(config *Config, identity []byte, password []byte) (*Subject, error) {
+	salt, verifier, err := config.NewVerifier(identity, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subject{
+		Name: "srp.Server " + config.Group.Name,
+		N:    config.Group.N,
+		NewSession: func() (Target, error) {
+			server, err := NewServer(config, identity, salt, verifier)
+			if err != nil {
+				return nil, err
+			}
+			return &serverSession{server: server}, nil
+		},
+		Proof: func(premaster *big.Int, salt []byte, A *big.Int, B *big.Int) []byte {
+			return config.evidence(identity, salt, A, B, config.hash(premaster.Bytes()))
+		},
+		Login: func(session Target) (*big.Int, []byte, error) {
+			client, err := NewClient(config, identity, password)
+			if err != nil {
+				return nil, nil, err
+			}
+			salt, B, err := session.Start(client.Public())
+			if err != nil {
+				return nil, nil, err
+			}
+			m1, err := client.Respond(salt, B)
+			if err != nil {
+				return nil, nil, err
+			}
+			return client.Public(), m1, session.Finish(m1)
+		},
+	}, nil
+}
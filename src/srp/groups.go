@@ -0,0 +1,73 @@
+package srp
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Primes of the RFC 5054 groups (appendix A), all of them safe primes. The
+// 3072 bit one is the same as the RFC 3526 group.
+const (
+	prime1024 = "eeaf0ab9adb38dd69c33f80afa8fc5e86072618775ff3c0b9ea2314c9c256576" +
+		"d674df7496ea81d3383b4813d692c6e0e0d5d8e250b98be48e495c1d6089dad1" +
+		"5dc7d7b46154d6b6ce8ef4ad69b15d4982559b297bcf1885c529f566660e57ec" +
+		"68edbc3c05726cc02fd4cbf4976eaa9afd5138fe8376435b9fc61d2fc0eb06e3"
+	prime1536 = "9def3cafb939277ab1f12a8617a47bbbdba51df499ac4c80beeea9614b19cc4d" +
+		"5f4f5f556e27cbde51c6a94be4607a291558903ba0d0f84380b655bb9a22e8dc" +
+		"df028a7cec67f0d08134b1c8b97989149b609e0be3bab63d47548381dbc5b1fc" +
+		"764e3f4b53dd9da1158bfd3e2b9c8cf56edf019539349627db2fd53d24b7c486" +
+		"65772e437d6c7f8ce442734af7ccb7ae837c264ae3a9beb87f8a2fe9b8b5292e" +
+		"5a021fff5e91479e8ce7a28c2442c6f315180f93499a234dcf76e3fed135f9bb"
+	prime2048 = "ac6bdb41324a9a9bf166de5e1389582faf72b6651987ee07fc3192943db56050" +
+		"a37329cbb4a099ed8193e0757767a13dd52312ab4b03310dcd7f48a9da04fd50" +
+		"e8083969edb767b0cf6095179a163ab3661a05fbd5faaae82918a9962f0b93b8" +
+		"55f97993ec975eeaa80d740adbf4ff747359d041d5c33ea71d281e446b14773b" +
+		"ca97b43a23fb801676bd207a436c6481f1d2b9078717461a5b9d32e688f87748" +
+		"544523b524b0d57d5ea77a2775d2ecfa032cfbdbf52fb3786160279004e57ae6" +
+		"af874e7303ce53299ccc041c7bc308d82a5698f3a8d0c38271ae35f8e9dbfbb6" +
+		"94b5c803d89f7ae435de236d525f54759b65e372fcd68ef20fa7111f9e4aff73"
+	prime3072 = "ffffffffffffffffc90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74" +
+		"020bbea63b139b22514a08798e3404ddef9519b3cd3a431b302b0a6df25f1437" +
+		"4fe1356d6d51c245e485b576625e7ec6f44c42e9a637ed6b0bff5cb6f406b7ed" +
+		"ee386bfb5a899fa5ae9f24117c4b1fe649286651ece45b3dc2007cb8a163bf05" +
+		"98da48361c55d39a69163fa8fd24cf5f83655d23dca3ad961c62f356208552bb" +
+		"9ed529077096966d670c354e4abc9804f1746c08ca18217c32905e462e36ce3b" +
+		"e39e772c180e86039b2783a2ec07a28fb5c55df06f4c52c9de2bcbf695581718" +
+		"3995497cea956ae515d2261898fa051015728e5a8aaac42dad33170d04507a33" +
+		"a85521abdf1cba64ecfb850458dbef0a8aea71575d060c7db3970f85a6e1e4c7" +
+		"abf5ae8cdb0933d71e8c94e04a25619dcee3d2261ad2ee6bf12ffa06d98a0864" +
+		"d87602733ec86a64521f2b18177b200cbbe117577a615d6c770988c0bad946e2" +
+		"08e24fa074e5ab3143db5bfce0fd108e4b82d120a93ad2caffffffffffffffff"
+)
+
+// Group is an SRP group: the safe prime N and the generator g.
+type Group struct {
+	Name string
+	N    *big.Int
+	G    *big.Int
+}
+
+func newGroup(name string, hexN string, g int64) *Group {
+	n, ok := new(big.Int).SetString(hexN, 16)
+	if !ok {
+		panic(fmt.Errorf("Bad prime for group %s", name))
+	}
+	return &Group{name, n, big.NewInt(g)}
+}
+
+// Groups holds the RFC 5054 groups, by size in bits.
+var Groups = map[int]*Group{
+	1024: newGroup("rfc5054-1024", prime1024, 2),
+	1536: newGroup("rfc5054-1536", prime1536, 2),
+	2048: newGroup("rfc5054-2048", prime2048, 2),
+	3072: newGroup("rfc5054-3072", prime3072, 5),
+}
+
+// LookupGroup finds the RFC 5054 group of the given size.
+func LookupGroup(bits int) (*Group, error) {
+	group, ok := Groups[bits]
+	if !ok {
+		return nil, fmt.Errorf("No %d bit SRP group", bits)
+	}
+	return group, nil
+}
@@ -0,0 +1,305 @@
+// Package srp implements SRP-6a as RFC 5054 specifies it, with the evidence
+// messages of RFC 2945, as a proper version of the set5 SRPClient and
+// SRPServer.
+package srp
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"hash"
+	"math/big"
+)
+
+// Size in bytes of the salts NewVerifier makes
+const SALT_SIZE = 16
+
+// Size in bits of the private values a and b. RFC 5054 asks for at least 256.
+const PRIVATE_BITS = 256
+
+// Config is what client and server agree on before an exchange: the group
+// and the hash everything is built with.
+type Config struct {
+	Group   *Group
+	NewHash func() hash.Hash
+}
+
+// NewConfig picks the RFC 5054 group of the given size, with newHash, such as
+// sha1.New or sha256.New.
+func NewConfig(bits int, newHash func() hash.Hash) (*Config, error) {
+	group, err := LookupGroup(bits)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{group, newHash}, nil
+}
+
+// SHA1Config and SHA256Config are the usual 2048 bit configurations.
+func SHA1Config() *Config {
+	return &Config{Groups[2048], sha1.New}
+}
+
+func SHA256Config() *Config {
+	return &Config{Groups[2048], sha256.New}
+}
+
+// hash is H() over the concatenation of parts.
+func (c *Config) hash(parts ...[]byte) []byte {
+	h := c.NewHash()
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+func (c *Config) hashInt(parts ...[]byte) *big.Int {
+	return new(big.Int).SetBytes(c.hash(parts...))
+}
+
+// pad is PAD() from RFC 5054: n left padded with zeroes to the size of N.
+// Anything too big for that is left as it is.
+func (c *Config) pad(n *big.Int) []byte {
+	out := make([]byte, (c.Group.N.BitLen()+7)/8)
+	bytes := n.Bytes()
+	if len(bytes) > len(out) {
+		return bytes
+	}
+	copy(out[len(out)-len(bytes):], bytes)
+	return out
+}
+
+// K is the multiplier k = H(N | PAD(g)).
+func (c *Config) K() *big.Int {
+	return c.hashInt(c.Group.N.Bytes(), c.pad(c.Group.G))
+}
+
+// X is the private key x = H(s | H(I | ":" | P)) derived from the password.
+func (c *Config) X(salt []byte, identity []byte, password []byte) *big.Int {
+	inner := c.hash(identity, []byte(":"), password)
+	return c.hashInt(salt, inner)
+}
+
+// Verifier is v = g^x % N, what the server keeps instead of the password.
+func (c *Config) Verifier(salt []byte, identity []byte, password []byte) *big.Int {
+	return new(big.Int).Exp(c.Group.G, c.X(salt, identity, password), c.Group.N)
+}
+
+// NewVerifier picks a fresh salt for identity, and works out its verifier.
+func (c *Config) NewVerifier(identity []byte, password []byte) ([]byte, *big.Int, error) {
+	salt := make([]byte, SALT_SIZE)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	return salt, c.Verifier(salt, identity, password), nil
+}
+
+// U is the scrambling parameter u = H(PAD(A) | PAD(B)).
+func (c *Config) U(A *big.Int, B *big.Int) *big.Int {
+	return c.hashInt(c.pad(A), c.pad(B))
+}
+
+// ValidPublic checks a public value from the other side. Anything that is 0
+// mod N gives away the session key, so it has to be refused, and honest
+// values are always reduced, so anything outside [1, N-1] is refused too.
+func (c *Config) ValidPublic(public *big.Int) error {
+	if new(big.Int).Mod(public, c.Group.N).Sign() == 0 {
+		return fmt.Errorf("Public value is 0 mod N")
+	}
+	if public.Sign() <= 0 || public.Cmp(c.Group.N) >= 0 {
+		return fmt.Errorf("Public value is out of range")
+	}
+	return nil
+}
+
+// evidence is M1 = H(H(N) XOR H(g) | H(I) | s | A | B | K), the client's proof
+// that it has K.
+func (c *Config) evidence(identity []byte, salt []byte, A *big.Int, B *big.Int, key []byte) []byte {
+	hn := c.hash(c.Group.N.Bytes())
+	hg := c.hash(c.Group.G.Bytes())
+	for i := range hn {
+		hn[i] ^= hg[i]
+	}
+	return c.hash(hn, c.hash(identity), salt, A.Bytes(), B.Bytes(), key)
+}
+
+// serverEvidence is M2 = H(A | M1 | K), the server's proof that it has K.
+func (c *Config) serverEvidence(A *big.Int, m1 []byte, key []byte) []byte {
+	return c.hash(A.Bytes(), m1, key)
+}
+
+func randomPrivate() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), PRIVATE_BITS))
+}
+
+// Client is the side of an exchange that knows the password.
+type Client struct {
+	config   *Config
+	identity []byte
+	password []byte
+
+	a      *big.Int
+	public *big.Int
+
+	premaster *big.Int
+	key       []byte
+	m1        []byte
+}
+
+// NewClient starts an exchange for identity with a fresh private value.
+func NewClient(config *Config, identity []byte, password []byte) (*Client, error) {
+	a, err := randomPrivate()
+	if err != nil {
+		return nil, err
+	}
+	return newClient(config, identity, password, a), nil
+}
+
+func newClient(config *Config, identity []byte, password []byte, a *big.Int) *Client {
+	public := new(big.Int).Exp(config.Group.G, a, config.Group.N)
+	return &Client{config: config, identity: identity, password: password, a: a, public: public}
+}
+
+// Public is A = g^a % N, to be sent along with the identity.
+func (c *Client) Public() *big.Int {
+	return c.public
+}
+
+// Respond takes the salt and B from the server, and returns M1 to prove the
+// client got the same session key.
+func (c *Client) Respond(salt []byte, B *big.Int) ([]byte, error) {
+	config := c.config
+	n := config.Group.N
+	if err := config.ValidPublic(B); err != nil {
+		return nil, err
+	}
+	u := config.U(c.public, B)
+	if u.Sign() == 0 {
+		return nil, fmt.Errorf("Scrambling parameter is 0")
+	}
+	x := config.X(salt, c.identity, c.password)
+
+	// S = (B - k * g^x) ^ (a + u * x) % N
+	base := new(big.Int).Exp(config.Group.G, x, n)
+	base.Mul(base, config.K())
+	base.Sub(B, base)
+	base.Mod(base, n)
+	exponent := new(big.Int).Mul(u, x)
+	exponent.Add(exponent, c.a)
+
+	c.premaster = base.Exp(base, exponent, n)
+	c.key = config.hash(c.premaster.Bytes())
+	c.m1 = config.evidence(c.identity, salt, c.public, B, c.key)
+	return c.m1, nil
+}
+
+// Verify checks M2 from the server, which proves the server knew the
+// verifier. Until it passes, the session key should not be used.
+func (c *Client) Verify(m2 []byte) error {
+	if c.m1 == nil {
+		return fmt.Errorf("Verify: Respond has not been called")
+	}
+	expected := c.config.serverEvidence(c.public, c.m1, c.key)
+	if subtle.ConstantTimeCompare(expected, m2) != 1 {
+		return fmt.Errorf("Server evidence does not match")
+	}
+	return nil
+}
+
+// SessionKey is K = H(S), once Respond has been called.
+func (c *Client) SessionKey() []byte {
+	return c.key
+}
+
+// Server is the side of an exchange that knows the verifier.
+type Server struct {
+	config   *Config
+	identity []byte
+	salt     []byte
+	verifier *big.Int
+
+	b      *big.Int
+	public *big.Int
+
+	premaster *big.Int
+	key       []byte
+}
+
+// NewServer answers an exchange for identity, whose salt and verifier the
+// server has on record, with a fresh private value.
+func NewServer(config *Config, identity []byte, salt []byte, verifier *big.Int) (*Server, error) {
+	b, err := randomPrivate()
+	if err != nil {
+		return nil, err
+	}
+	return newServer(config, identity, salt, verifier, b), nil
+}
+
+func newServer(config *Config, identity []byte, salt []byte, verifier *big.Int, b *big.Int) *Server {
+	n := config.Group.N
+
+	// B = k * v + g^b % N
+	public := new(big.Int).Mul(config.K(), verifier)
+	public.Add(public, new(big.Int).Exp(config.Group.G, b, n))
+	public.Mod(public, n)
+	return &Server{config: config, identity: identity, salt: salt, verifier: verifier, b: b, public: public}
+}
+
+// Salt is the salt to send to the client, along with Public.
+func (s *Server) Salt() []byte {
+	return s.salt
+}
+
+// Public is B = k * v + g^b % N.
+func (s *Server) Public() *big.Int {
+	return s.public
+}
+
+// Verify takes A and M1 from the client, and returns M2 if M1 proves the
+// client knew the password.
+func (s *Server) Verify(A *big.Int, m1 []byte) ([]byte, error) {
+	config := s.config
+	n := config.Group.N
+	if err := config.ValidPublic(A); err != nil {
+		return nil, err
+	}
+	u := config.U(A, s.public)
+
+	// S = (A * v^u) ^ b % N
+	premaster := new(big.Int).Exp(s.verifier, u, n)
+	premaster.Mul(premaster, A)
+	premaster.Mod(premaster, n)
+	premaster.Exp(premaster, s.b, n)
+	key := config.hash(premaster.Bytes())
+
+	expected := config.evidence(s.identity, s.salt, A, s.public, key)
+	if subtle.ConstantTimeCompare(expected, m1) != 1 {
+		return nil, fmt.Errorf("Client evidence does not match")
+	}
+	s.premaster = premaster
+	s.key = key
+	return config.serverEvidence(A, m1, key), nil
+}
+
+// SessionKey is K = H(S), once Verify has passed.
+func (s *Server) SessionKey() []byte {
+	return s.key
+}
+
+// Exchange runs a whole exchange between client and server, and returns the
+// session key they agreed on.
+func Exchange(client *Client, server *Server) ([]byte, error) {
+	m1, err := client.Respond(server.Salt(), server.Public())
+	if err != nil {
+		return nil, err
+	}
+	m2, err := server.Verify(client.Public(), m1)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Verify(m2); err != nil {
+		return nil, err
+	}
+	return client.SessionKey(), nil
+}
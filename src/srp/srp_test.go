@@ -0,0 +1,219 @@
+package srp
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func fromHex(t *testing.T, s string) *big.Int {
+	n, ok := new(big.Int).SetString(strings.Join(strings.Fields(s), ""), 16)
+	if !ok {
+		t.Fatalf("Bad hex %q", s)
+	}
+	return n
+}
+
+// The test vector of RFC 5054 appendix B
+func TestRFC5054Vector(t *testing.T) {
+	config, err := NewConfig(1024, sha1.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := []byte("alice")
+	password := []byte("password123")
+	salt, _ := hex.DecodeString("beb25379d1a8581eb5a727673a2441ee")
+	a := fromHex(t, "60975527035cf2ad1989806f0407210bc81edc04e2762a56afd529ddda2d4393")
+	b := fromHex(t, "e487cb59d31ac550471e81f00f6928e01dda08e974a004f49e61f5d105284d20")
+
+	check := func(name string, got *big.Int, want string) {
+		if got.Cmp(fromHex(t, want)) != 0 {
+			t.Errorf("%s = %x, want %s", name, got, want)
+		}
+	}
+	check("k", config.K(), "7556aa045aef2cdd07abaf0f665c3e818913186f")
+	check("x", config.X(salt, identity, password), "94b7555aabe9127cc58ccf4993db6cf84d16c124")
+
+	v := config.Verifier(salt, identity, password)
+	check("v", v, `
+		7e273de8696ffc4f4e337d05b4b375beb0dde1569e8fa00a9886d8129bada1f1
+		822223ca1a605b530e379ba4729fdc59f105b4787e5186f5c671085a1447b52a
+		48cf1970b4fb6f8400bbf4cebfbb168152e08ab5ea53d15c1aff87b2b9da6e04
+		e058ad51cc72bfc9033b564e26480d78e955a5e29e7ab245db2be315e2099afb`)
+
+	client := newClient(config, identity, password, a)
+	check("A", client.Public(), `
+		61d5e490f6f1b79547b0704c436f523dd0e560f0c64115bb72557ec44352e890
+		3211c04692272d8b2d1a5358a2cf1b6e0bfcf99f921530ec8e39356179eae45e
+		42ba92aeaced825171e1e8b9af6d9c03e1327f44be087ef06530e69f66615261
+		eef54073ca11cf5858f0edfdfe15efeab349ef5d76988a3672fac47b0769447b`)
+
+	server := newServer(config, identity, salt, v, b)
+	check("B", server.Public(), `
+		bd0c61512c692c0cb6d041fa01bb152d4916a1e77af46ae105393011baf38964
+		dc46a0670dd125b95a981652236f99d9b681cbf87837ec996c6da04453728610
+		d0c6ddb58b318885d7d82c7f8deb75ce7bd4fbaa37089e6f9c6059f388838e7a
+		00030b331eb76840910440b1b27aaeaeeb4012b7d7665238a8e3fb004b117b58`)
+
+	check("u", config.U(client.Public(), server.Public()), "ce38b9593487da98554ed47d70a7ae5f462ef019")
+
+	if _, err := Exchange(client, server); err != nil {
+		t.Fatal(err)
+	}
+	premaster := `
+		b0dc82babcf30674ae450c0287745e7990a3381f63b387aaf271a10d233861e3
+		59b48220f7c4693c9ae12b0a6f67809f0876e2d013800d6c41bb59b6d5979b5c
+		00a172b4a2a5903a0bdcaf8a709585eb2afafa8f3499b200210dcc1f10eb3394
+		3cd67fc88a2f39a4be5bec4ec0a3212dc346d7e474b29ede8a469ffeca686e5a`
+	check("client S", client.premaster, premaster)
+	check("server S", server.premaster, premaster)
+}
+
+func TestExchange(t *testing.T) {
+	identity := []byte("abe@example.com")
+	for _, config := range []*Config{SHA1Config(), SHA256Config(), {Groups[3072], sha256.New}} {
+		salt, v, err := config.NewVerifier(identity, []byte("password"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client, _ := NewClient(config, identity, []byte("password"))
+		server, _ := NewServer(config, identity, salt, v)
+		key, err := Exchange(client, server)
+		if err != nil {
+			t.Errorf("%s: %v", config.Group.Name, err)
+			continue
+		}
+		if !bytes.Equal(key, server.SessionKey()) {
+			t.Errorf("%s: client and server keys differ", config.Group.Name)
+		}
+
+		client, _ = NewClient(config, identity, []byte("passw0rd"))
+		server, _ = NewServer(config, identity, salt, v)
+		if _, err := Exchange(client, server); err == nil {
+			t.Errorf("%s: wrong password was accepted", config.Group.Name)
+		}
+		if server.SessionKey() != nil {
+			t.Errorf("%s: server kept a key for the wrong password", config.Group.Name)
+		}
+	}
+}
+
+func TestBadPublicValues(t *testing.T) {
+	config := SHA256Config()
+	identity := []byte("abe@example.com")
+	salt, v, _ := config.NewVerifier(identity, []byte("password"))
+	n := config.Group.N
+	one := big.NewInt(1)
+
+	// Without a check, the server's S would be 0 for the multiples of N, and
+	// M1 = H(..., H(0)) would get anyone in. The others are out of range.
+	bad := []*big.Int{
+		new(big.Int),
+		n,
+		new(big.Int).Mul(n, big.NewInt(2)),
+		new(big.Int).Neg(one),
+		new(big.Int).Add(n, one),
+		new(big.Int).Add(new(big.Int).Mul(n, n), one),
+	}
+	for _, bad := range bad {
+		server, _ := NewServer(config, identity, salt, v)
+		m1 := config.evidence(identity, salt, bad, server.Public(), config.hash(nil))
+		if _, err := server.Verify(bad, m1); err == nil {
+			t.Errorf("Server accepted A = %v", bad)
+		}
+
+		client, _ := NewClient(config, identity, []byte("password"))
+		if _, err := client.Respond(salt, bad); err == nil {
+			t.Errorf("Client accepted B = %v", bad)
+		}
+	}
+}
+
+// sloppySession is a Target for a Server that forgot to check A. With reduce
+// set, it works with A mod N instead of A as it came.
+type sloppySession struct {
+	serverSession
+	reduce bool
+}
+
+func (s *sloppySession) Finish(proof []byte) error {
+	server := s.server
+	config := server.config
+	n := config.Group.N
+	A := s.A
+	if s.reduce {
+		A = new(big.Int).Mod(A, n)
+	}
+	u := config.U(A, server.public)
+	premaster := new(big.Int).Exp(server.verifier, u, n)
+	premaster.Mul(premaster, A)
+	premaster.Mod(premaster, n)
+	premaster.Exp(premaster, server.b, n)
+	key := config.hash(premaster.Bytes())
+	if !bytes.Equal(proof, config.evidence(server.identity, server.salt, A, server.public, key)) {
+		return fmt.Errorf("Client evidence does not match")
+	}
+	return nil
+}
+
+func TestAttacks(t *testing.T) {
+	subject, err := NewServerSubject(SHA256Config(), []byte("abe@example.com"), []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes := RunAttacks(subject)
+	if len(outcomes) != len(AttackCases) {
+		t.Fatalf("Got %d outcomes for %d cases", len(outcomes), len(AttackCases))
+	}
+	for _, o := range outcomes {
+		if !o.Passed() {
+			t.Errorf("%s", Report(subject.Name, outcomes))
+			break
+		}
+	}
+
+	// The harness has to notice a server that does not check A, whether it
+	// takes it as it comes or mod N
+	newSession := subject.NewSession
+	sloppy := func(reduce bool) map[string]bool {
+		subject.NewSession = func() (Target, error) {
+			session, err := newSession()
+			if err != nil {
+				return nil, err
+			}
+			return &sloppySession{*session.(*serverSession), reduce}, nil
+		}
+		let := map[string]bool{}
+		for _, o := range RunAttacks(subject) {
+			let[o.Case] = o.Authenticated
+		}
+		return let
+	}
+	expectations := []struct {
+		reduce bool
+		in     []string
+		out    []string
+	}{
+		{false, []string{"Honest client", "A = 0", "A = N", "A = 2N", "A = N*k", "A = N^2", "A = -N"}, []string{"A + N", "A - N", "Replayed transcript"}},
+		{true, []string{"Honest client", "A + N", "A - N"}, []string{"Replayed transcript"}},
+	}
+	for _, e := range expectations {
+		let := sloppy(e.reduce)
+		for _, name := range e.in {
+			if !let[name] {
+				t.Errorf("Sloppy server (reduce %v) should have let %q in", e.reduce, name)
+			}
+		}
+		for _, name := range e.out {
+			if let[name] {
+				t.Errorf("Sloppy server (reduce %v) should not have let %q in", e.reduce, name)
+			}
+		}
+	}
+}
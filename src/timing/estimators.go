@@ -0,0 +1,174 @@
+package timing
+
+import (
+	"math"
+	"sort"
+)
+
+// Estimator decides which of several candidates is the slow one, from
+// timing samples in nanoseconds.
+type Estimator interface {
+	// Statistic summarises the samples of one candidate, higher meaning
+	// slower.
+	Statistic(samples []float64) float64
+
+	// Separation says how sure we can be that best really is slower than
+	// runnerUp, roughly in standard errors.
+	Separation(best []float64, runnerUp []float64) float64
+}
+
+// Min keeps the fastest sample, which is the one least affected by context
+// switches, page faults, etc.
+type Min struct{}
+
+func (Min) Statistic(samples []float64) float64 {
+	min := math.Inf(1)
+	for _, s := range samples {
+		min = math.Min(min, s)
+	}
+	return min
+}
+
+func (m Min) Separation(best []float64, runnerUp []float64) float64 {
+	return robustSeparation(m, best, runnerUp)
+}
+
+// Median is the middle sample, which ignores the odd slow outlier.
+type Median struct{}
+
+func (Median) Statistic(samples []float64) float64 {
+	return quantile(samples, 0.5)
+}
+
+func (m Median) Separation(best []float64, runnerUp []float64) float64 {
+	return robustSeparation(m, best, runnerUp)
+}
+
+// TrimmedMean is the mean once the Trim fraction of samples at both ends
+// have been dropped.
+type TrimmedMean struct {
+	Trim float64
+}
+
+func (t TrimmedMean) Statistic(samples []float64) float64 {
+	sorted := sortedCopy(samples)
+	drop := int(t.Trim * float64(len(sorted)))
+	if 2*drop >= len(sorted) {
+		return quantile(samples, 0.5)
+	}
+	return mean(sorted[drop : len(sorted)-drop])
+}
+
+// Separation is Yuen's t statistic, which goes with trimmed means the way
+// Welch's goes with plain ones.
+func (t TrimmedMean) Separation(best []float64, runnerUp []float64) float64 {
+	errBest, okBest := t.squaredError(best)
+	errRunnerUp, okRunnerUp := t.squaredError(runnerUp)
+	if !okBest || !okRunnerUp {
+		return 0
+	}
+	return separation(t.Statistic(best)-t.Statistic(runnerUp), math.Sqrt(errBest+errRunnerUp))
+}
+
+// squaredError estimates the squared standard error of the trimmed mean
+// from the winsorized variance, where the trimmed samples are replaced by
+// the closest ones kept rather than dropped.
+func (t TrimmedMean) squaredError(samples []float64) (float64, bool) {
+	sorted := sortedCopy(samples)
+	drop := int(t.Trim * float64(len(sorted)))
+	kept := len(sorted) - 2*drop
+	if kept < 2 {
+		return 0, false
+	}
+
+	for i := 0; i < drop; i++ {
+		sorted[i] = sorted[drop]
+		sorted[len(sorted)-1-i] = sorted[len(sorted)-1-drop]
+	}
+	return float64(len(sorted)-1) * variance(sorted) / float64(kept*(kept-1)), true
+}
+
+// Welch compares the plain means with Welch's t-test, which is the right
+// thing when the noise is well behaved.
+type Welch struct{}
+
+func (Welch) Statistic(samples []float64) float64 {
+	return mean(samples)
+}
+
+// Separation is Welch's t statistic.
+func (Welch) Separation(best []float64, runnerUp []float64) float64 {
+	if len(best) < 2 || len(runnerUp) < 2 {
+		return 0
+	}
+	err := math.Sqrt(variance(best)/float64(len(best)) + variance(runnerUp)/float64(len(runnerUp)))
+	return separation(mean(best)-mean(runnerUp), err)
+}
+
+// robustSeparation measures the gap between the statistics of best and
+// runnerUp against their spread, using the median absolute deviation so
+// that outliers do not blow it up.
+func robustSeparation(e Estimator, best []float64, runnerUp []float64) float64 {
+	if len(best) < 2 || len(runnerUp) < 2 {
+		return 0
+	}
+	spreadBest := mad(best)
+	spreadRunnerUp := mad(runnerUp)
+	err := math.Sqrt(spreadBest*spreadBest/float64(len(best)) + spreadRunnerUp*spreadRunnerUp/float64(len(runnerUp)))
+	return separation(e.Statistic(best)-e.Statistic(runnerUp), err)
+}
+
+// separation divides gap by err, treating noiseless samples as certain.
+func separation(gap float64, err float64) float64 {
+	if err == 0 {
+		if gap > 0 {
+			return math.Inf(1)
+		}
+		return 0
+	}
+	return gap / err
+}
+
+func mean(samples []float64) float64 {
+	total := 0.0
+	for _, s := range samples {
+		total += s
+	}
+	return total / float64(len(samples))
+}
+
+func variance(samples []float64) float64 {
+	m := mean(samples)
+	total := 0.0
+	for _, s := range samples {
+		total += (s - m) * (s - m)
+	}
+	return total / float64(len(samples)-1)
+}
+
+// mad is the median absolute deviation, scaled to match the standard
+// deviation for normal noise.
+func mad(samples []float64) float64 {
+	median := quantile(samples, 0.5)
+	deviations := make([]float64, len(samples))
+	for i, s := range samples {
+		deviations[i] = math.Abs(s - median)
+	}
+	return 1.4826 * quantile(deviations, 0.5)
+}
+
+func quantile(samples []float64, q float64) float64 {
+	sorted := sortedCopy(samples)
+	pos := q * float64(len(sorted)-1)
+	low := int(pos)
+	if low+1 >= len(sorted) {
+		return sorted[low]
+	}
+	return sorted[low] + (pos-float64(low))*(sorted[low+1]-sorted[low])
+}
+
+func sortedCopy(samples []float64) []float64 {
+	sorted := append([]float64{}, samples...)
+	sort.Float64s(sorted)
+	return sorted
+}
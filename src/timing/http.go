@@ -0,0 +1,26 @@
+package timing
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// HTTPOracle times GET requests to the URL built by url for every guess,
+// until the whole response has been read. Errors count as however long they
+// took to come back. If client is nil, http.DefaultClient is used.
+func HTTPOracle(client *http.Client, url func(guess []byte) string) Oracle {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(guess []byte) time.Duration {
+		start := time.Now()
+		resp, err := client.Get(url(guess))
+		if err == nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		return time.Since(start)
+	}
+}
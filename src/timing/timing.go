@@ -0,0 +1,247 @@
+// Package timing recovers secrets byte by byte from how long a comparison
+// takes to fail, treating every timing as a noisy sample rather than trusting
+// any single run.
+package timing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Oracle times one attempt at guess, which is always Config.Length bytes
+// long.
+type Oracle func(guess []byte) time.Duration
+
+// Kinds of Event
+const (
+	// A byte was picked with enough confidence, or an earlier byte was
+	// settled on after a backtrack
+	EVENT_GUESS = iota
+	// A byte was picked without reaching the confidence threshold
+	EVENT_UNSURE
+	// An earlier byte was swapped for its next best candidate
+	EVENT_BACKTRACK
+	// The whole secret was found
+	EVENT_DONE
+)
+
+// Event describes progress made by Attack, for Config.Report. When a byte is
+// settled on after a backtrack, Separation and Samples are those of the
+// measurement of the byte after it, which is what settled it.
+type Event struct {
+	Kind       int
+	Index      int
+	Guess      []byte
+	Separation float64
+	Samples    int
+}
+
+// Config describes the secret to recover and how hard to try. Zero fields
+// get sensible defaults.
+type Config struct {
+	// Length of the secret, in bytes
+	Length int
+
+	// Which estimator picks the slowest candidate, Median by default
+	Estimator Estimator
+
+	// Separation to reach before a byte is accepted, 4 by default
+	Confidence float64
+
+	// Samples taken per candidate in each round, 5 by default, and the
+	// most taken per candidate for one byte, 100 by default
+	Batch      int
+	MaxSamples int
+
+	// Bytes tried at every position, all 256 by default
+	Candidates []byte
+
+	// How many of the best candidates to go back and try for a byte when
+	// the byte after it gives no clear answer, 3 by default
+	Alternatives int
+
+	// The last Tail bytes get brute forced with Check instead of timed.
+	// Check, if given, also verifies the final answer.
+	Tail  int
+	Check func(guess []byte) bool
+
+	// Report gets told about progress, if not nil
+	Report func(Event)
+}
+
+func (c Config) withDefaults() Config {
+	if c.Estimator == nil {
+		c.Estimator = Median{}
+	}
+	if c.Confidence == 0 {
+		c.Confidence = 4
+	}
+	if c.Batch == 0 {
+		c.Batch = 5
+	}
+	if c.MaxSamples == 0 {
+		c.MaxSamples = 100
+	}
+	if c.Candidates == nil {
+		c.Candidates = make([]byte, 256)
+		for i := range c.Candidates {
+			c.Candidates[i] = byte(i)
+		}
+	}
+	if c.Alternatives == 0 {
+		c.Alternatives = 3
+	}
+	return c
+}
+
+// measurement is what came out of timing every candidate at one position.
+type measurement struct {
+	ranking    []byte
+	separation float64
+	samples    int
+}
+
+// attack holds the state of one run of Attack.
+type attack struct {
+	oracle Oracle
+	config Config
+	guess  []byte
+}
+
+func (a *attack) report(kind int, index int, m *measurement) {
+	if a.config.Report == nil {
+		return
+	}
+	event := Event{Kind: kind, Index: index, Guess: append([]byte{}, a.guess...)}
+	if m != nil {
+		event.Separation = m.separation
+		event.Samples = m.samples
+	}
+	a.config.Report(event)
+}
+
+// measure times every candidate at index, adding samples in rounds until the
+// slowest one stands out or MaxSamples is reached. Candidates are sampled in
+// turn so that drift in the timings hits all of them alike.
+func (a *attack) measure(index int) *measurement {
+	candidates := a.config.Candidates
+	samples := make([][]float64, len(candidates))
+
+	m := &measurement{}
+	for m.samples < a.config.MaxSamples {
+		for b := 0; b < a.config.Batch; b++ {
+			for i, c := range candidates {
+				a.guess[index] = c
+				samples[i] = append(samples[i], float64(a.oracle(a.guess)))
+			}
+		}
+		m.samples += a.config.Batch
+
+		order := make([]int, len(candidates))
+		stats := make([]float64, len(candidates))
+		for i := range order {
+			order[i] = i
+			stats[i] = a.config.Estimator.Statistic(samples[i])
+		}
+		sort.SliceStable(order, func(x, y int) bool { return stats[order[x]] > stats[order[y]] })
+
+		m.ranking = make([]byte, len(order))
+		for i, o := range order {
+			m.ranking[i] = candidates[o]
+		}
+		if len(order) < 2 {
+			m.separation = 0
+			break
+		}
+		m.separation = a.config.Estimator.Separation(samples[order[0]], samples[order[1]])
+		if m.separation >= a.config.Confidence {
+			break
+		}
+	}
+	return m
+}
+
+// Attack recovers a secret of config.Length bytes from oracle. The slowest
+// candidate is picked at every position, taking more samples until it stands
+// out. When no candidate stands out at a position, the earlier byte was
+// probably wrong, so its next best candidates get a go before settling for
+// whichever gave the clearest answer.
+func Attack(oracle Oracle, config Config) ([]byte, error) {
+	config = config.withDefaults()
+	if config.Tail > 0 && config.Check == nil {
+		return nil, fmt.Errorf("Need a Check function to brute force the last %d bytes", config.Tail)
+	}
+	if config.Tail > config.Length {
+		return nil, fmt.Errorf("Cannot brute force %d bytes of a %d byte secret", config.Tail, config.Length)
+	}
+
+	a := &attack{oracle: oracle, config: config, guess: make([]byte, config.Length)}
+	rankings := make([][]byte, config.Length)
+
+	for i := 0; i < config.Length-config.Tail; i++ {
+		m := a.measure(i)
+		if m.separation < config.Confidence && i > 0 {
+			m = a.backtrack(i, rankings[i-1], m)
+		}
+
+		rankings[i] = m.ranking
+		a.guess[i] = m.ranking[0]
+		if m.separation >= config.Confidence {
+			a.report(EVENT_GUESS, i, m)
+		} else {
+			a.report(EVENT_UNSURE, i, m)
+		}
+	}
+
+	if config.Tail > 0 {
+		if !a.bruteForce(config.Length - config.Tail) {
+			return a.guess, fmt.Errorf("Could not find the last %d bytes", config.Tail)
+		}
+	} else if config.Check != nil && !config.Check(a.guess) {
+		return a.guess, fmt.Errorf("Guess %x did not pass the check", a.guess)
+	}
+	a.report(EVENT_DONE, config.Length-1, nil)
+	return a.guess, nil
+}
+
+// backtrack tries the next best candidates for the byte before index, given
+// that first was an unclear measurement at index. It leaves the byte before
+// index on whichever candidate gave the clearest measurement, reports it
+// again, and returns that measurement.
+func (a *attack) backtrack(index int, previous []byte, first *measurement) *measurement {
+	best := first
+	bestPrevious := a.guess[index-1]
+
+	for alt := 1; alt < a.config.Alternatives && alt < len(previous); alt++ {
+		a.guess[index-1] = previous[alt]
+		a.report(EVENT_BACKTRACK, index-1, nil)
+
+		m := a.measure(index)
+		if m.separation > best.separation {
+			best, bestPrevious = m, previous[alt]
+		}
+		if m.separation >= a.config.Confidence {
+			break
+		}
+	}
+
+	a.guess[index-1] = bestPrevious
+	a.report(EVENT_GUESS, index-1, best)
+	return best
+}
+
+// bruteForce tries every combination of candidates for the bytes from start
+// onwards, until Check passes.
+func (a *attack) bruteForce(start int) bool {
+	if start == len(a.guess) {
+		return a.config.Check(a.guess)
+	}
+	for _, c := range a.config.Candidates {
+		a.guess[start] = c
+		if a.bruteForce(start + 1) {
+			return true
+		}
+	}
+	return false
+}
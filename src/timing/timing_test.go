@@ -0,0 +1,171 @@
+package timing
+
+import (
+	"bytes"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// fakeOracle pretends every matching leading byte takes 1µs, with normal
+// noise and (if spikes is set) the odd large spike on top.
+func fakeOracle(secret []byte, noise float64, spikes bool, rng *rand.Rand) Oracle {
+	return func(guess []byte) time.Duration {
+		matching := 0
+		for matching < len(secret) && guess[matching] == secret[matching] {
+			matching++
+		}
+		t := 10000 + 1000*float64(matching) + noise*rng.NormFloat64()
+		if spikes && rng.Intn(50) == 0 {
+			t += 20000
+		}
+		return time.Duration(t)
+	}
+}
+
+func TestEstimators(t *testing.T) {
+	slow := []float64{110, 111, 109, 110, 500, 110, 112}
+	fast := []float64{100, 101, 99, 100, 100, 400, 102}
+
+	for _, e := range []Estimator{Min{}, Median{}, TrimmedMean{0.2}, Welch{}} {
+		if e.Statistic(slow) <= e.Statistic(fast) {
+			t.Errorf("%T thinks %v is faster than %v", e, slow, fast)
+		}
+	}
+
+	for _, e := range []Estimator{Median{}, TrimmedMean{0.2}} {
+		if sep := e.Separation(slow, fast); sep < 4 {
+			t.Errorf("%T only separates by %f", e, sep)
+		}
+		if sep := e.Separation(fast, slow); sep > 0 {
+			t.Errorf("%T separates the wrong way by %f", e, sep)
+		}
+	}
+
+	if Median.Statistic(Median{}, []float64{3, 1, 2, 10}) != 2.5 {
+		t.Errorf("Median of 1, 2, 3, 10 should be 2.5")
+	}
+}
+
+func TestAttack(t *testing.T) {
+	secret := []byte{0x13, 0x37, 0xca, 0xfe, 0x00, 0xff}
+	rng := rand.New(rand.NewSource(1))
+
+	// Welch's t-test is thrown off by spikes, the others are not
+	estimators := []Estimator{Min{}, Median{}, TrimmedMean{0.2}, Welch{}}
+	for _, e := range estimators {
+		_, isWelch := e.(Welch)
+		events := 0
+		found, err := Attack(fakeOracle(secret, 300, !isWelch, rng), Config{
+			Length:    len(secret),
+			Estimator: e,
+			Report:    func(Event) { events++ },
+		})
+		if err != nil {
+			t.Errorf("%T: %v", e, err)
+		}
+		if !bytes.Equal(found, secret) {
+			t.Errorf("%T found %x instead of %x", e, found, secret)
+		}
+		if events < len(secret) {
+			t.Errorf("%T only reported %d events", e, events)
+		}
+	}
+}
+
+func TestAttackTail(t *testing.T) {
+	secret := []byte("secret")
+	rng := rand.New(rand.NewSource(2))
+	candidates := []byte("abcdefghijklmnopqrstuvwxyz")
+
+	found, err := Attack(fakeOracle(secret, 300, true, rng), Config{
+		Length:     len(secret),
+		Candidates: candidates,
+		Tail:       2,
+		Check:      func(guess []byte) bool { return bytes.Equal(guess, secret) },
+	})
+	if err != nil || !bytes.Equal(found, secret) {
+		t.Errorf("Found %q (%v) instead of %q", found, err, secret)
+	}
+
+	if _, err := Attack(fakeOracle(secret, 300, true, rng), Config{Length: 6, Tail: 2}); err == nil {
+		t.Errorf("Brute forcing without a check worked")
+	}
+}
+
+func TestAttackBacktrack(t *testing.T) {
+	secret := []byte("abcd")
+	candidates := []byte("abcdefgh")
+	rng := rand.New(rand.NewSource(3))
+	honest := fakeOracle(secret, 100, true, rng)
+
+	// A decoy which looks even slower than the right byte at position 1,
+	// but leads nowhere after that
+	oracle := func(guess []byte) time.Duration {
+		if guess[0] == 'a' && guess[1] == 'h' {
+			return honest([]byte("a\x00\x00\x00")) + 1500
+		}
+		return honest(guess)
+	}
+
+	var events []Event
+	found, err := Attack(oracle, Config{
+		Length:     len(secret),
+		Candidates: candidates,
+		Report:     func(e Event) { events = append(events, e) },
+	})
+	if err != nil || !bytes.Equal(found, secret) {
+		t.Errorf("Found %q (%v) instead of %q", found, err, secret)
+	}
+
+	// The backtrack has to be followed by a guess which corrects the decoy
+	var kinds []int
+	backtracked, corrected := false, false
+	for _, e := range events {
+		kinds = append(kinds, e.Kind)
+		backtracked = backtracked || e.Kind == EVENT_BACKTRACK
+		if backtracked && e.Kind == EVENT_GUESS && e.Index == 1 && e.Guess[1] == secret[1] {
+			corrected = true
+		}
+	}
+	if !backtracked {
+		t.Errorf("Expected the decoy to cause a backtrack, got events %v", kinds)
+	}
+	if !corrected {
+		t.Errorf("Expected a guess for the corrected byte after the backtrack, got events %v", kinds)
+	}
+}
+
+func TestHTTPOracle(t *testing.T) {
+	secret := []byte("ab")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		guess := []byte(r.FormValue("guess"))
+		for i := range secret {
+			if i >= len(guess) || guess[i] != secret[i] {
+				http.Error(w, "Wrong", http.StatusInternalServerError)
+				return
+			}
+			time.Sleep(2 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	oracle := HTTPOracle(nil, func(guess []byte) string {
+		return server.URL + "/?guess=" + url.QueryEscape(string(guess))
+	})
+	if wrong, right := oracle([]byte("xx")), oracle([]byte("ax")); right < wrong+time.Millisecond {
+		t.Errorf("Right first byte took %v, wrong one %v", right, wrong)
+	}
+
+	found, err := Attack(oracle, Config{
+		Length:     len(secret),
+		Candidates: []byte("abcd"),
+		MaxSamples: 10,
+	})
+	if err != nil || !bytes.Equal(found, secret) {
+		t.Errorf("Found %q (%v) instead of %q", found, err, secret)
+	}
+}